@@ -13,18 +13,30 @@ type GitLab struct {
 	InstanceURL       string
 	ApplicationID     string
 	ApplicationSecret string
+	// WebhookSecret is the secret token GitLab sends with webhooks. Webhooks are disabled if it is empty
+	WebhookSecret string
 
 	GroupIDs []int
 
 	UserUpdateInterval time.Duration
 	ItemUpdateInterval time.Duration
+	// ItemReconcileInterval is how often the index is checked for items that no longer exist in GitLab
+	ItemReconcileInterval time.Duration
+	// MembershipUpdateInterval is how often project and group memberships are refreshed for permission checks
+	MembershipUpdateInterval time.Duration
+	// SyncConcurrency is how many groups, and requests for each group, are fetched from GitLab at the same time
+	SyncConcurrency int
 }
 
 type Config struct {
 	Port            int
 	MeiliMasterKey  string
 	HostExternalURL string
+	GraphDataDir    string
 	GitLab          GitLab
+
+	// SessionSecret signs session cookies. A random secret is used if it is empty
+	SessionSecret string
 }
 
 func FromEnvironment() (c *Config, err error) {
@@ -70,6 +82,33 @@ func FromEnvironment() (c *Config, err error) {
 		return nil, fmt.Errorf("item update interval is not a valid duration: %w", err)
 	}
 
+	itemReconcileInterval, err := getEnv("ITEM_RECONCILE_INTERVAL", "1h")
+	if err != nil {
+		return nil, err
+	}
+	c.GitLab.ItemReconcileInterval, err = time.ParseDuration(itemReconcileInterval)
+	if err != nil {
+		return nil, fmt.Errorf("item reconcile interval is not a valid duration: %w", err)
+	}
+
+	membershipUpdateInterval, err := getEnv("MEMBERSHIP_UPDATE_INTERVAL", "30m")
+	if err != nil {
+		return nil, err
+	}
+	c.GitLab.MembershipUpdateInterval, err = time.ParseDuration(membershipUpdateInterval)
+	if err != nil {
+		return nil, fmt.Errorf("membership update interval is not a valid duration: %w", err)
+	}
+
+	syncConcurrency, err := getEnv("SYNC_CONCURRENCY", "4")
+	if err != nil {
+		return nil, err
+	}
+	c.GitLab.SyncConcurrency, err = strconv.Atoi(syncConcurrency)
+	if err != nil || c.GitLab.SyncConcurrency < 1 {
+		return nil, fmt.Errorf("sync concurrency %q is not a positive number", syncConcurrency)
+	}
+
 	c.MeiliMasterKey, err = getEnv("MEILI_MASTER_KEY")
 	if err != nil {
 		return nil, err
@@ -80,6 +119,16 @@ func FromEnvironment() (c *Config, err error) {
 		return nil, err
 	}
 
+	c.GraphDataDir, err = getEnv("GRAPH_DATA_DIR", "data")
+	if err != nil {
+		return nil, err
+	}
+
+	c.SessionSecret, err = getEnv("SESSION_SECRET", "")
+	if err != nil {
+		return nil, err
+	}
+
 	c.GitLab.ApiKey, err = getEnv("GITLAB_API_KEY")
 	if err != nil {
 		return nil, err
@@ -100,6 +149,11 @@ func FromEnvironment() (c *Config, err error) {
 		return nil, err
 	}
 
+	c.GitLab.WebhookSecret, err = getEnv("GITLAB_WEBHOOK_SECRET", "")
+	if err != nil {
+		return nil, err
+	}
+
 	groupIDs, ok := os.LookupEnv("GITLAB_GROUP_IDS")
 	if !ok {
 		return nil, fmt.Errorf("missing env variable %q", "GITLAB_GROUP_IDS")
@@ -0,0 +1,75 @@
+package events
+
+import "pathflux/meili"
+
+// Filter restricts which item events a subscriber receives. Empty sets match everything.
+// User events are never filtered
+type Filter struct {
+	ItemIDs  map[string]struct{}
+	GroupIDs map[int]struct{}
+	Kinds    map[meili.ItemKind]struct{}
+}
+
+func (f Filter) matchesItem(item meili.GitLabItem) bool {
+	if len(f.ItemIDs) > 0 {
+		if _, ok := f.ItemIDs[item.ID]; !ok {
+			return false
+		}
+	}
+	if len(f.GroupIDs) > 0 {
+		if _, ok := f.GroupIDs[item.GroupID]; !ok {
+			return false
+		}
+	}
+	if len(f.Kinds) > 0 {
+		if _, ok := f.Kinds[item.Kind]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesRemovedID checks a removed item. Its group is no longer known, so the group filter is ignored
+func (f Filter) matchesRemovedID(id string) bool {
+	if len(f.ItemIDs) > 0 {
+		if _, ok := f.ItemIDs[id]; !ok {
+			return false
+		}
+	}
+	if len(f.Kinds) > 0 {
+		kind, _, ok := meili.ParseItemID(id)
+		if !ok {
+			return false
+		}
+		if _, ok := f.Kinds[kind]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// apply returns the part of the event that matches the filter, and whether anything is left
+func (f Filter) apply(ev Event) (Event, bool) {
+	switch ev.Type {
+	case EventItemsUpdated:
+		var items []meili.GitLabItem
+		for _, item := range ev.Items {
+			if f.matchesItem(item) {
+				items = append(items, item)
+			}
+		}
+		ev.Items = items
+		return ev, len(items) > 0
+	case EventItemsRemoved:
+		var ids []string
+		for _, id := range ev.RemovedIDs {
+			if f.matchesRemovedID(id) {
+				ids = append(ids, id)
+			}
+		}
+		ev.RemovedIDs = ids
+		return ev, len(ids) > 0
+	default:
+		return ev, true
+	}
+}
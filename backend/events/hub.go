@@ -0,0 +1,160 @@
+package events
+
+import (
+	"pathflux/meili"
+	"sync"
+)
+
+type EventType string
+
+const (
+	EventItemsUpdated EventType = "items_updated"
+	EventItemsRemoved EventType = "items_removed"
+	EventUsersUpdated EventType = "users_updated"
+)
+
+// Event describes changes noticed by the index sync
+type Event struct {
+	Type EventType `json:"type"`
+
+	Items      []meili.GitLabItem `json:"items,omitempty"`
+	RemovedIDs []string           `json:"removed_ids,omitempty"`
+	Users      []meili.User       `json:"users,omitempty"`
+}
+
+// subscriberBuffer is how many events a subscriber may lag behind before it is dropped
+const subscriberBuffer = 64
+
+// AccessChecker decides which items a user may see
+type AccessChecker interface {
+	CanSee(userID int, item meili.GitLabItem) bool
+}
+
+// Hub distributes index events to all subscribers
+type Hub struct {
+	lock sync.Mutex
+
+	subscribers map[*Subscriber]struct{}
+	// access filters updated items per subscriber. Without it, no item updates are sent
+	access AccessChecker
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// Subscriber receives the events matching its filter, limited to the items its user can see
+type Subscriber struct {
+	hub    *Hub
+	userID int
+	filter Filter
+	events chan Event
+}
+
+// Events returns the channel of events. It is closed if the subscriber falls too far behind
+func (s *Subscriber) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops the subscription
+func (s *Subscriber) Close() {
+	s.hub.lock.Lock()
+	defer s.hub.lock.Unlock()
+
+	if _, ok := s.hub.subscribers[s]; ok {
+		delete(s.hub.subscribers, s)
+		close(s.events)
+	}
+}
+
+// SetAccess sets the checker used to hide items from subscribers that can't see them
+func (h *Hub) SetAccess(access AccessChecker) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.access = access
+}
+
+func (h *Hub) Subscribe(userID int, filter Filter) *Subscriber {
+	sub := &Subscriber{
+		hub:    h,
+		userID: userID,
+		filter: filter,
+		events: make(chan Event, subscriberBuffer),
+	}
+
+	h.lock.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.lock.Unlock()
+
+	return sub
+}
+
+func (h *Hub) publish(ev Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for sub := range h.subscribers {
+		filtered, ok := sub.filter.apply(ev)
+		if !ok {
+			continue
+		}
+		if filtered, ok = h.visible(sub.userID, filtered); !ok {
+			continue
+		}
+
+		select {
+		case sub.events <- filtered:
+		default:
+			// The subscriber is too slow; it has to reconnect and refetch what it shows
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// visible removes the updated items the user can't see, and returns whether anything is left.
+// Removed items are only sent by ID, so they are left alone. Callers must hold the lock
+func (h *Hub) visible(userID int, ev Event) (Event, bool) {
+	if ev.Type != EventItemsUpdated {
+		return ev, true
+	}
+	if h.access == nil {
+		return ev, false
+	}
+
+	var items []meili.GitLabItem
+	for _, item := range ev.Items {
+		if h.access.CanSee(userID, item) {
+			items = append(items, item)
+		}
+	}
+	ev.Items = items
+	return ev, len(items) > 0
+}
+
+// PublishItems can be used as meili.ItemUpdateCallback
+func (h *Hub) PublishItems(items []meili.GitLabItem) {
+	h.publish(Event{
+		Type:  EventItemsUpdated,
+		Items: items,
+	})
+}
+
+// PublishRemovedItems can be used as meili.ItemRemoveCallback
+func (h *Hub) PublishRemovedItems(ids []string) {
+	h.publish(Event{
+		Type:       EventItemsRemoved,
+		RemovedIDs: ids,
+	})
+}
+
+// PublishUsers can be used as meili.UserUpdateCallback
+func (h *Hub) PublishUsers(users []meili.User) {
+	h.publish(Event{
+		Type:  EventUsersUpdated,
+		Users: users,
+	})
+}
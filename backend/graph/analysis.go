@@ -0,0 +1,300 @@
+package graph
+
+import (
+	"fmt"
+	"math"
+	"pathflux/meili"
+)
+
+// EstimateKind selects the per-node estimate used for the critical path
+type EstimateKind string
+
+const (
+	// EstimateAuto uses weights if any node has one, otherwise time estimates, otherwise counts nodes
+	EstimateAuto EstimateKind = ""
+	// EstimateWeight uses the GitLab issue weight
+	EstimateWeight EstimateKind = "weight"
+	// EstimateTime uses the GitLab time estimate in hours
+	EstimateTime EstimateKind = "time"
+	// EstimateCount counts every node as 1
+	EstimateCount EstimateKind = "count"
+)
+
+func ParseEstimateKind(s string) (EstimateKind, error) {
+	switch k := EstimateKind(s); k {
+	case EstimateAuto, EstimateWeight, EstimateTime, EstimateCount:
+		return k, nil
+	default:
+		return "", fmt.Errorf("%w: unknown estimate %q", ErrInvalid, s)
+	}
+}
+
+// estimate returns the estimate of a node. Only nodes with a hydrated Item have weights and time estimates
+func (k EstimateKind) estimate(n *Node) float64 {
+	switch k {
+	case EstimateCount:
+		return 1
+	case EstimateWeight:
+		if n.Item != nil {
+			return float64(n.Item.Weight)
+		}
+	case EstimateTime:
+		if n.Item != nil {
+			return float64(n.Item.TimeEstimate) / 3600
+		}
+	}
+	return 0
+}
+
+// resolve turns EstimateAuto into the kind that is used for the nodes
+func (k EstimateKind) resolve(nodes []*Node) EstimateKind {
+	if k != EstimateAuto {
+		return k
+	}
+
+	for _, kind := range []EstimateKind{EstimateWeight, EstimateTime} {
+		for _, n := range nodes {
+			if kind.estimate(n) > 0 {
+				return kind
+			}
+		}
+	}
+	return EstimateCount
+}
+
+// Cycle is a strongly connected part of the graph. Every node in it depends on every other node
+type Cycle struct {
+	NodeIDs []string `json:"node_ids"`
+	// EdgeIDs are the edges between the nodes of the cycle, removing some of them breaks it
+	EdgeIDs []string `json:"edge_ids"`
+}
+
+// Schedule is the earliest and latest time a node can be worked on without delaying the whole graph
+type Schedule struct {
+	Estimate       float64 `json:"estimate"`
+	EarliestStart  float64 `json:"earliest_start"`
+	EarliestFinish float64 `json:"earliest_finish"`
+	LatestStart    float64 `json:"latest_start"`
+	LatestFinish   float64 `json:"latest_finish"`
+	// Slack is how much the node can be delayed. Nodes on the critical path have none
+	Slack float64 `json:"slack"`
+}
+
+type Analysis struct {
+	Acyclic bool    `json:"acyclic"`
+	Cycles  []Cycle `json:"cycles"`
+
+	// The following fields are only set if the graph is acyclic
+
+	// Order lists all node IDs so that every node comes after the nodes it depends on
+	Order []string `json:"order,omitempty"`
+
+	Estimate EstimateKind `json:"estimate,omitempty"`
+	// CriticalPath is the chain of dependent nodes with the largest total estimate
+	CriticalPath       []string            `json:"critical_path,omitempty"`
+	CriticalPathLength float64             `json:"critical_path_length"`
+	Schedule           map[string]Schedule `json:"schedule,omitempty"`
+}
+
+// dependencyEdges returns the edges that model dependencies: the source has to be done before the target.
+// Generated relates_to and child edges don't order work, and self loops have no meaning, so they are ignored
+func (g *Graph) dependencyEdges() []*Edge {
+	var edges []*Edge
+	for _, e := range g.Edges {
+		if e.Source == e.Target || e.Label == string(meili.RelationRelatesTo) || e.Label == string(meili.RelationChild) {
+			continue
+		}
+		edges = append(edges, e)
+	}
+	return edges
+}
+
+// Cycles finds all cycles with Tarjan's strongly connected components algorithm. Callers must hold at least a read lock
+func (g *Graph) Cycles() []Cycle {
+	edges := g.dependencyEdges()
+
+	var outgoing = make(map[string][]string, len(g.Nodes))
+	for _, e := range edges {
+		outgoing[e.Source] = append(outgoing[e.Source], e.Target)
+	}
+
+	var (
+		index     = make(map[string]int, len(g.Nodes))
+		lowlink   = make(map[string]int, len(g.Nodes))
+		onStack   = make(map[string]bool, len(g.Nodes))
+		stack     []string
+		next      int
+		component = make(map[string]int, len(g.Nodes))
+		cycles    []Cycle
+	)
+
+	var connect func(id string)
+	connect = func(id string) {
+		index[id] = next
+		lowlink[id] = next
+		next++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, target := range outgoing[id] {
+			if _, visited := index[target]; !visited {
+				connect(target)
+				lowlink[id] = min(lowlink[id], lowlink[target])
+			} else if onStack[target] {
+				lowlink[id] = min(lowlink[id], index[target])
+			}
+		}
+
+		if lowlink[id] != index[id] {
+			return
+		}
+
+		var nodes []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			nodes = append(nodes, top)
+			if top == id {
+				break
+			}
+		}
+
+		// Single nodes are only cycles with a self loop, and those are ignored
+		if len(nodes) > 1 {
+			for _, n := range nodes {
+				component[n] = len(cycles) + 1
+			}
+			cycles = append(cycles, Cycle{NodeIDs: nodes})
+		}
+	}
+
+	for _, n := range g.Nodes {
+		if _, visited := index[n.ID]; !visited {
+			connect(n.ID)
+		}
+	}
+
+	for _, e := range edges {
+		c := component[e.Source]
+		if c != 0 && c == component[e.Target] {
+			cycles[c-1].EdgeIDs = append(cycles[c-1].EdgeIDs, e.ID)
+		}
+	}
+
+	return cycles
+}
+
+// TopologicalOrder returns the node IDs so that every node comes after the nodes it depends on,
+// or ok = false if the graph has a cycle. Callers must hold at least a read lock
+func (g *Graph) TopologicalOrder() (order []string, ok bool) {
+	var outgoing = make(map[string][]string, len(g.Nodes))
+	var indegree = make(map[string]int, len(g.Nodes))
+	for _, e := range g.dependencyEdges() {
+		outgoing[e.Source] = append(outgoing[e.Source], e.Target)
+		indegree[e.Target]++
+	}
+
+	var queue []string
+	for _, n := range g.Nodes {
+		if indegree[n.ID] == 0 {
+			queue = append(queue, n.ID)
+		}
+	}
+
+	order = make([]string, 0, len(g.Nodes))
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		order = append(order, id)
+
+		for _, target := range outgoing[id] {
+			indegree[target]--
+			if indegree[target] == 0 {
+				queue = append(queue, target)
+			}
+		}
+	}
+
+	if len(order) != len(g.Nodes) {
+		return nil, false
+	}
+	return order, true
+}
+
+// Analyze finds cycles and, if there are none, the topological order, critical path and schedule.
+// Weights and time estimates are taken from the nodes' Item, so the graph should be hydrated. Callers must hold at least a read lock
+func (g *Graph) Analyze(kind EstimateKind) *Analysis {
+	a := &Analysis{
+		Cycles: g.Cycles(),
+	}
+	if a.Cycles == nil {
+		a.Cycles = []Cycle{}
+	}
+
+	order, ok := g.TopologicalOrder()
+	if !ok {
+		return a
+	}
+	a.Acyclic = true
+	a.Order = order
+
+	a.Estimate = kind.resolve(g.Nodes)
+
+	var incoming = make(map[string][]string, len(g.Nodes))
+	var outgoing = make(map[string][]string, len(g.Nodes))
+	for _, e := range g.dependencyEdges() {
+		incoming[e.Target] = append(incoming[e.Target], e.Source)
+		outgoing[e.Source] = append(outgoing[e.Source], e.Target)
+	}
+
+	a.Schedule = make(map[string]Schedule, len(g.Nodes))
+	for _, n := range g.Nodes {
+		a.Schedule[n.ID] = Schedule{Estimate: a.Estimate.estimate(n)}
+	}
+
+	// Forward pass: a node can start once everything it depends on is finished
+	var end string
+	for _, id := range order {
+		s := a.Schedule[id]
+		for _, dep := range incoming[id] {
+			s.EarliestStart = math.Max(s.EarliestStart, a.Schedule[dep].EarliestFinish)
+		}
+		s.EarliestFinish = s.EarliestStart + s.Estimate
+		a.Schedule[id] = s
+
+		if end == "" || s.EarliestFinish > a.CriticalPathLength {
+			end = id
+			a.CriticalPathLength = s.EarliestFinish
+		}
+	}
+
+	// Backward pass: a node must finish before anything depending on it has to start
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		s := a.Schedule[id]
+		s.LatestFinish = a.CriticalPathLength
+		for _, next := range outgoing[id] {
+			s.LatestFinish = math.Min(s.LatestFinish, a.Schedule[next].LatestStart)
+		}
+		s.LatestStart = s.LatestFinish - s.Estimate
+		s.Slack = s.LatestStart - s.EarliestStart
+		a.Schedule[id] = s
+	}
+
+	// Walk back from the node that finishes last along the dependencies that determined its start
+	for id := end; id != ""; {
+		a.CriticalPath = append([]string{id}, a.CriticalPath...)
+
+		var prev string
+		for _, dep := range incoming[id] {
+			if a.Schedule[dep].EarliestFinish == a.Schedule[id].EarliestStart &&
+				(prev == "" || a.Schedule[dep].Estimate > a.Schedule[prev].Estimate) {
+				prev = dep
+			}
+		}
+		id = prev
+	}
+
+	return a
+}
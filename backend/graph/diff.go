@@ -0,0 +1,167 @@
+package graph
+
+// NodeChange is a node that exists in both versions of a graph but differs between them
+type NodeChange struct {
+	Before *Node `json:"before"`
+	After  *Node `json:"after"`
+}
+
+type EdgeChange struct {
+	Before *Edge `json:"before"`
+	After  *Edge `json:"after"`
+}
+
+// Diff lists the changes between two versions of a graph
+type Diff struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+
+	// Name is set if the graph was renamed
+	Name *NameChange `json:"name,omitempty"`
+
+	AddedNodes   []*Node      `json:"added_nodes"`
+	RemovedNodes []*Node      `json:"removed_nodes"`
+	ChangedNodes []NodeChange `json:"changed_nodes"`
+
+	AddedEdges   []*Edge      `json:"added_edges"`
+	RemovedEdges []*Edge      `json:"removed_edges"`
+	ChangedEdges []EdgeChange `json:"changed_edges"`
+}
+
+type NameChange struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// sameNode reports whether two nodes are equal, ignoring the hydrated Item
+func sameNode(a, b *Node) bool {
+	return a.ID == b.ID && a.Type == b.Type && a.Position == b.Position && a.Content == b.Content && a.ItemID == b.ItemID
+}
+
+// replacesNode reports whether b can't be reached from a with update_node, which only changes positions and content
+func replacesNode(a, b *Node) bool {
+	return a.Type != b.Type || a.ItemID != b.ItemID
+}
+
+// NewDiff compares two versions of a graph. Callers must hold at least a read lock on both
+func NewDiff(from, to *Graph) *Diff {
+	d := &Diff{
+		From:         from.Version,
+		To:           to.Version,
+		AddedNodes:   []*Node{},
+		RemovedNodes: []*Node{},
+		ChangedNodes: []NodeChange{},
+		AddedEdges:   []*Edge{},
+		RemovedEdges: []*Edge{},
+		ChangedEdges: []EdgeChange{},
+	}
+
+	if from.Name != to.Name {
+		d.Name = &NameChange{Before: from.Name, After: to.Name}
+	}
+
+	for _, n := range from.Nodes {
+		if to.Node(n.ID) == nil {
+			d.RemovedNodes = append(d.RemovedNodes, n)
+		}
+	}
+	for _, n := range to.Nodes {
+		before := from.Node(n.ID)
+		if before == nil {
+			d.AddedNodes = append(d.AddedNodes, n)
+		} else if !sameNode(before, n) {
+			d.ChangedNodes = append(d.ChangedNodes, NodeChange{Before: before, After: n})
+		}
+	}
+
+	for _, e := range from.Edges {
+		if to.Edge(e.ID) == nil {
+			d.RemovedEdges = append(d.RemovedEdges, e)
+		}
+	}
+	for _, e := range to.Edges {
+		before := from.Edge(e.ID)
+		if before == nil {
+			d.AddedEdges = append(d.AddedEdges, e)
+		} else if *before != *e {
+			d.ChangedEdges = append(d.ChangedEdges, EdgeChange{Before: before, After: e})
+		}
+	}
+
+	return d
+}
+
+// DiffOperations returns the operations that turn current into target. Edges are removed before the nodes they
+// connect and added after them, so every operation is valid when it is applied. Callers must hold at least a read lock on both
+func DiffOperations(current, target *Graph) []*Operation {
+	var ops []*Operation
+
+	// Nodes whose type or item changed can't be updated in place and are added again
+	var removedNodes = make(map[string]bool)
+	for _, n := range current.Nodes {
+		if t := target.Node(n.ID); t == nil || replacesNode(n, t) {
+			removedNodes[n.ID] = true
+		}
+	}
+
+	var removedEdges = make(map[string]bool)
+	for _, e := range current.Edges {
+		t := target.Edge(e.ID)
+		if t == nil || removedNodes[e.Source] || removedNodes[e.Target] ||
+			(*t != *e && (removedNodes[t.Source] || removedNodes[t.Target])) {
+			removedEdges[e.ID] = true
+			ops = append(ops, &Operation{Type: OpRemoveEdge, EdgeID: e.ID})
+		}
+	}
+
+	for _, n := range current.Nodes {
+		if removedNodes[n.ID] {
+			ops = append(ops, &Operation{Type: OpRemoveNode, NodeID: n.ID})
+		}
+	}
+
+	for _, n := range target.Nodes {
+		if c := current.Node(n.ID); c == nil || removedNodes[n.ID] {
+			node := *n
+			node.Item = nil
+			ops = append(ops, &Operation{Type: OpAddNode, Node: &node})
+		}
+	}
+
+	for _, n := range target.Nodes {
+		c := current.Node(n.ID)
+		if c == nil || removedNodes[n.ID] {
+			continue
+		}
+
+		op := &Operation{Type: OpUpdateNode, NodeID: n.ID}
+		if c.Position != n.Position {
+			position := n.Position
+			op.Position = &position
+		}
+		if c.Content != n.Content {
+			content := n.Content
+			op.Content = &content
+		}
+		if op.Position != nil || op.Content != nil {
+			ops = append(ops, op)
+		}
+	}
+
+	for _, e := range target.Edges {
+		edge := *e
+		c := current.Edge(e.ID)
+		switch {
+		case c == nil || removedEdges[e.ID]:
+			ops = append(ops, &Operation{Type: OpAddEdge, Edge: &edge})
+		case *c != *e:
+			ops = append(ops, &Operation{Type: OpUpdateEdge, Edge: &edge})
+		}
+	}
+
+	if current.Name != target.Name {
+		ops = append(ops, &Operation{Type: OpRename, Name: target.Name})
+	}
+
+	return ops
+}
@@ -0,0 +1,176 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"pathflux/meili"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNodeNotFound = errors.New("node not found")
+	ErrEdgeNotFound = errors.New("edge not found")
+	ErrInvalid      = errors.New("invalid graph change")
+)
+
+// The methods in this file change the graph in place. Callers must hold the write lock, usually by going through Manager.Update
+
+func (g *Graph) nodeIndex(id string) int {
+	for i, n := range g.Nodes {
+		if n.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (g *Graph) edgeIndex(id string) int {
+	for i, e := range g.Edges {
+		if e.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Node returns the node with the given ID or nil
+func (g *Graph) Node(id string) *Node {
+	if i := g.nodeIndex(id); i >= 0 {
+		return g.Nodes[i]
+	}
+	return nil
+}
+
+// Edge returns the edge with the given ID or nil
+func (g *Graph) Edge(id string) *Edge {
+	if i := g.edgeIndex(id); i >= 0 {
+		return g.Edges[i]
+	}
+	return nil
+}
+
+// AddNode adds the node to the graph. An ID is generated if the node has none
+func (g *Graph) AddNode(n *Node) error {
+	if !n.Type.Valid() {
+		return fmt.Errorf("%w: unknown node type %q", ErrInvalid, n.Type)
+	}
+
+	if n.Type == NodeTypeGitLabItem {
+		if _, _, ok := meili.ParseItemID(n.ItemID); !ok {
+			return fmt.Errorf("%w: invalid item ID %q", ErrInvalid, n.ItemID)
+		}
+	} else if n.ItemID != "" {
+		return fmt.Errorf("%w: only %s nodes can reference an item", ErrInvalid, NodeTypeGitLabItem)
+	}
+
+	// Item data is looked up when the graph is fetched and must not be stored
+	n.Item = nil
+
+	if n.ID == "" {
+		n.ID = uuid.NewString()
+	} else if g.nodeIndex(n.ID) >= 0 {
+		return fmt.Errorf("%w: node %q already exists", ErrInvalid, n.ID)
+	}
+
+	g.Nodes = append(g.Nodes, n)
+	return nil
+}
+
+// MoveNode sets the position of a node
+func (g *Graph) MoveNode(id string, pos Position) error {
+	n := g.Node(id)
+	if n == nil {
+		return ErrNodeNotFound
+	}
+
+	n.Position = pos
+	return nil
+}
+
+// SetNodeContent replaces the markdown content of a text node
+func (g *Graph) SetNodeContent(id string, content string) error {
+	n := g.Node(id)
+	if n == nil {
+		return ErrNodeNotFound
+	}
+
+	if n.Type != NodeTypeText {
+		return fmt.Errorf("%w: node %q of type %q has no content", ErrInvalid, id, n.Type)
+	}
+
+	n.Content = content
+	return nil
+}
+
+// RemoveNode removes a node and all edges connected to it
+func (g *Graph) RemoveNode(id string) error {
+	i := g.nodeIndex(id)
+	if i < 0 {
+		return ErrNodeNotFound
+	}
+
+	g.Nodes = append(g.Nodes[:i], g.Nodes[i+1:]...)
+
+	var edges = make([]*Edge, 0, len(g.Edges))
+	for _, e := range g.Edges {
+		if e.Source != id && e.Target != id {
+			edges = append(edges, e)
+		}
+	}
+	g.Edges = edges
+
+	return nil
+}
+
+func (g *Graph) validateEdge(e *Edge) error {
+	if g.nodeIndex(e.Source) < 0 {
+		return fmt.Errorf("%w: edge source %q does not exist", ErrInvalid, e.Source)
+	}
+	if g.nodeIndex(e.Target) < 0 {
+		return fmt.Errorf("%w: edge target %q does not exist", ErrInvalid, e.Target)
+	}
+	return nil
+}
+
+// AddEdge adds an edge between two existing nodes. An ID is generated if the edge has none
+func (g *Graph) AddEdge(e *Edge) error {
+	if err := g.validateEdge(e); err != nil {
+		return err
+	}
+
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	} else if g.edgeIndex(e.ID) >= 0 {
+		return fmt.Errorf("%w: edge %q already exists", ErrInvalid, e.ID)
+	}
+
+	g.Edges = append(g.Edges, e)
+	return nil
+}
+
+// ReplaceEdge overwrites the edge with the same ID
+func (g *Graph) ReplaceEdge(e *Edge) error {
+	i := g.edgeIndex(e.ID)
+	if i < 0 {
+		return ErrEdgeNotFound
+	}
+
+	if err := g.validateEdge(e); err != nil {
+		return err
+	}
+
+	g.Edges[i] = e
+	return nil
+}
+
+// RemoveEdge removes the edge with the given ID
+func (g *Graph) RemoveEdge(id string) error {
+	i := g.edgeIndex(id)
+	if i < 0 {
+		return ErrEdgeNotFound
+	}
+
+	g.Edges = append(g.Edges[:i], g.Edges[i+1:]...)
+	return nil
+}
@@ -0,0 +1,329 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"pathflux/meili"
+	"strconv"
+	"strings"
+)
+
+type ExportFormat string
+
+const (
+	ExportMermaid ExportFormat = "mermaid"
+	ExportDOT     ExportFormat = "dot"
+	ExportGraphML ExportFormat = "graphml"
+)
+
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch f := ExportFormat(strings.ToLower(s)); f {
+	case ExportMermaid, ExportDOT, ExportGraphML:
+		return f, nil
+	case "":
+		return ExportMermaid, nil
+	case "graphviz", "gv":
+		return ExportDOT, nil
+	default:
+		return "", fmt.Errorf("%w: unknown export format %q", ErrInvalid, s)
+	}
+}
+
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportDOT:
+		return "text/vnd.graphviz; charset=utf-8"
+	case ExportGraphML:
+		return "application/graphml+xml; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+func (f ExportFormat) Extension() string {
+	switch f {
+	case ExportDOT:
+		return "dot"
+	case ExportGraphML:
+		return "graphml"
+	default:
+		return "mmd"
+	}
+}
+
+// Export writes the graph in the given format. GitLab item nodes are labeled with the slug, title and state
+// of their Item if the graph is hydrated, otherwise with the item ID. Callers must hold at least a read lock
+func (g *Graph) Export(w io.Writer, format ExportFormat) error {
+	switch format {
+	case ExportMermaid:
+		return g.exportMermaid(w)
+	case ExportDOT:
+		return g.exportDOT(w)
+	case ExportGraphML:
+		return g.exportGraphML(w)
+	default:
+		return fmt.Errorf("%w: unknown export format %q", ErrInvalid, format)
+	}
+}
+
+// nodeLabel is the text shown for a node in exported graphs
+func nodeLabel(n *Node) string {
+	switch {
+	case n.Type == NodeTypeText:
+		return strings.TrimSpace(n.Content)
+	case n.Item != nil:
+		return fmt.Sprintf("%s: %s (%s)", n.Item.Slug, n.Item.Title, n.Item.State)
+	default:
+		return n.ItemID
+	}
+}
+
+func nodeURL(n *Node) string {
+	if n.Item != nil {
+		return n.Item.WebURL
+	}
+	return ""
+}
+
+func nodeState(n *Node) meili.GitLabItemState {
+	if n.Item != nil {
+		return n.Item.State
+	}
+	return ""
+}
+
+// undirected reports whether the edge has no direction, which is the case for related items
+func (e *Edge) undirected() bool {
+	return e.Label == string(meili.RelationRelatesTo)
+}
+
+// exportIDs assigns short identifiers to the nodes, as node IDs can contain characters the text formats don't allow
+func (g *Graph) exportIDs() map[string]string {
+	var ids = make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[n.ID] = "n" + strconv.Itoa(i)
+	}
+	return ids
+}
+
+// mermaidEscaper escapes labels with Mermaid's entity codes, which start with #
+var mermaidEscaper = strings.NewReplacer(
+	"#", "#35;",
+	`"`, "#quot;",
+	"<", "#lt;",
+	">", "#gt;",
+	"\r\n", "<br>",
+	"\n", "<br>",
+)
+
+func (g *Graph) exportMermaid(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	ids := g.exportIDs()
+
+	fmt.Fprintf(bw, "---\ntitle: %s\n---\n", strconv.Quote(g.Name))
+	fmt.Fprintln(bw, "flowchart TB")
+
+	var states = make(map[meili.GitLabItemState][]string)
+	for _, n := range g.Nodes {
+		fmt.Fprintf(bw, "    %s[\"%s\"]\n", ids[n.ID], mermaidEscaper.Replace(nodeLabel(n)))
+		if url := nodeURL(n); url != "" {
+			fmt.Fprintf(bw, "    click %s href \"%s\" _blank\n", ids[n.ID], strings.ReplaceAll(url, `"`, "%22"))
+		}
+		if state := nodeState(n); state != "" {
+			states[state] = append(states[state], ids[n.ID])
+		}
+	}
+
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.undirected() {
+			arrow = "---"
+		}
+		if e.Label != "" {
+			fmt.Fprintf(bw, "    %s %s|\"%s\"| %s\n", ids[e.Source], arrow, mermaidEscaper.Replace(e.Label), ids[e.Target])
+		} else {
+			fmt.Fprintf(bw, "    %s %s %s\n", ids[e.Source], arrow, ids[e.Target])
+		}
+	}
+
+	// Color GitLab items like GitLab shows their state
+	var stateColors = []struct {
+		state meili.GitLabItemState
+		style string
+	}{
+		{meili.GitLabItemStateOpened, "fill:#d9f2e3,stroke:#108548"},
+		{meili.GitLabItemStateMerged, "fill:#e1d8f9,stroke:#694cc0"},
+		{meili.GitLabItemStateClosed, "fill:#cbe2f9,stroke:#1f75cb"},
+		{meili.GitLabItemStateLocked, "fill:#ececef,stroke:#737278"},
+	}
+	for _, sc := range stateColors {
+		if len(states[sc.state]) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "    classDef %s %s\n", sc.state, sc.style)
+		fmt.Fprintf(bw, "    class %s %s\n", strings.Join(states[sc.state], ","), sc.state)
+	}
+
+	return bw.Flush()
+}
+
+var dotEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+func (g *Graph) exportDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	ids := g.exportIDs()
+
+	fmt.Fprintf(bw, "digraph %s {\n", dotQuote(g.Name))
+	fmt.Fprintln(bw, "    rankdir=TB;")
+	fmt.Fprintln(bw, "    node [shape=box, style=rounded];")
+
+	for _, n := range g.Nodes {
+		var attrs = []string{"label=" + dotQuote(nodeLabel(n))}
+		if url := nodeURL(n); url != "" {
+			attrs = append(attrs, "URL="+dotQuote(url))
+		}
+		if state := nodeState(n); state != "" {
+			attrs = append(attrs, "class="+dotQuote(string(state)))
+		}
+		fmt.Fprintf(bw, "    %s [%s];\n", ids[n.ID], strings.Join(attrs, ", "))
+	}
+
+	for _, e := range g.Edges {
+		var attrs []string
+		if e.Label != "" {
+			attrs = append(attrs, "label="+dotQuote(e.Label))
+		}
+		if e.undirected() {
+			attrs = append(attrs, "dir=none")
+		}
+
+		fmt.Fprintf(bw, "    %s -> %s", ids[e.Source], ids[e.Target])
+		if len(attrs) > 0 {
+			fmt.Fprintf(bw, " [%s]", strings.Join(attrs, ", "))
+		}
+		fmt.Fprintln(bw, ";")
+	}
+
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID       string        `xml:"id,attr"`
+	Source   string        `xml:"source,attr"`
+	Target   string        `xml:"target,attr"`
+	Directed bool          `xml:"directed,attr"`
+	Data     []graphMLData `xml:"data"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Data        []graphMLData `xml:"data"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+// graphMLKeys declares the attributes exported nodes and edges can have
+var graphMLKeys = []graphMLKey{
+	{ID: "name", For: "graph", AttrName: "name", AttrType: "string"},
+	{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+	{ID: "type", For: "node", AttrName: "type", AttrType: "string"},
+	{ID: "item_id", For: "node", AttrName: "item_id", AttrType: "string"},
+	{ID: "url", For: "node", AttrName: "url", AttrType: "string"},
+	{ID: "state", For: "node", AttrName: "state", AttrType: "string"},
+	{ID: "x", For: "node", AttrName: "x", AttrType: "double"},
+	{ID: "y", For: "node", AttrName: "y", AttrType: "double"},
+	{ID: "edge_label", For: "edge", AttrName: "label", AttrType: "string"},
+}
+
+func (g *Graph) exportGraphML(w io.Writer) error {
+	var doc graphMLDocument
+	doc.XMLNS = "http://graphml.graphdrawing.org/xmlns"
+	doc.Keys = graphMLKeys
+	doc.Graph.ID = g.ID
+	doc.Graph.EdgeDefault = "directed"
+	doc.Graph.Data = []graphMLData{{Key: "name", Value: g.Name}}
+
+	// GraphML IDs only have to be unique, so the graph's own IDs are kept
+	for _, n := range g.Nodes {
+		var data = []graphMLData{
+			{Key: "label", Value: nodeLabel(n)},
+			{Key: "type", Value: string(n.Type)},
+		}
+		if n.ItemID != "" {
+			data = append(data, graphMLData{Key: "item_id", Value: n.ItemID})
+		}
+		if url := nodeURL(n); url != "" {
+			data = append(data, graphMLData{Key: "url", Value: url})
+		}
+		if state := nodeState(n); state != "" {
+			data = append(data, graphMLData{Key: "state", Value: string(state)})
+		}
+		data = append(data,
+			graphMLData{Key: "x", Value: strconv.FormatFloat(n.Position.X, 'f', -1, 64)},
+			graphMLData{Key: "y", Value: strconv.FormatFloat(n.Position.Y, 'f', -1, 64)},
+		)
+
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: n.ID, Data: data})
+	}
+
+	for _, e := range g.Edges {
+		edge := graphMLEdge{
+			ID:       e.ID,
+			Source:   e.Source,
+			Target:   e.Target,
+			Directed: !e.undirected(),
+		}
+		if e.Label != "" {
+			edge.Data = []graphMLData{{Key: "edge_label", Value: e.Label}}
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, edge)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,152 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"pathflux/meili"
+	"slices"
+)
+
+const (
+	DefaultGenerateDepth = 2
+	MaxGenerateDepth     = 5
+	// MaxGeneratedNodes keeps generated graphs readable and bounds the number of lookups
+	MaxGeneratedNodes = 200
+)
+
+// DefaultGenerateRelations are the relations followed when no others are requested: issue links and the epic hierarchy
+var DefaultGenerateRelations = []meili.RelationType{
+	meili.RelationRelatesTo,
+	meili.RelationBlocks,
+	meili.RelationBlockedBy,
+	meili.RelationParent,
+	meili.RelationChild,
+}
+
+// RelationsFunc returns the relations of the item with the given ID, usually meili.DBClient.GetItemRelations for one user
+type RelationsFunc func(ctx context.Context, itemID string) ([]meili.ItemRelation, error)
+
+type GenerateOptions struct {
+	Name string
+
+	// Roots are the item IDs the graph starts from
+	Roots []string
+	// Depth is how many relations away from a root an item can be
+	Depth int
+	// Relations are the relation types that are followed, DefaultGenerateRelations if empty
+	Relations []meili.RelationType
+}
+
+// edgeDirection returns the relation type as seen from the edge's source. Edges always point
+// from the blocking, parent or closing item to the other one, so inverse relations are flipped
+func edgeDirection(t meili.RelationType) (label meili.RelationType, flipped bool) {
+	switch t {
+	case meili.RelationBlockedBy, meili.RelationParent, meili.RelationClosedBy:
+		return t.Inverse(), true
+	default:
+		return t, false
+	}
+}
+
+// Generate builds a graph of GitLab item nodes by following relations from the roots breadth-first.
+// Edges are added between all included items, even those at the depth limit. Nodes are placed with a layered layout
+func Generate(ctx context.Context, relations RelationsFunc, opts GenerateOptions) (*Graph, error) {
+	if len(opts.Roots) == 0 {
+		return nil, fmt.Errorf("%w: no root items", ErrInvalid)
+	}
+	if opts.Depth < 0 || opts.Depth > MaxGenerateDepth {
+		return nil, fmt.Errorf("%w: depth must be between 0 and %d", ErrInvalid, MaxGenerateDepth)
+	}
+	followed := opts.Relations
+	if len(followed) == 0 {
+		followed = DefaultGenerateRelations
+	}
+
+	g := &Graph{
+		Name:  opts.Name,
+		Nodes: []*Node{},
+		Edges: []*Edge{},
+	}
+
+	// Item IDs are unique within a generated graph, so they double as node IDs
+	var depth = make(map[string]int)
+	var queue []string
+	var addItem = func(id string, d int) error {
+		if _, ok := depth[id]; ok {
+			return nil
+		}
+		if len(depth) >= MaxGeneratedNodes {
+			return nil
+		}
+		if err := g.AddNode(&Node{ID: id, Type: NodeTypeGitLabItem, ItemID: id}); err != nil {
+			return err
+		}
+		depth[id] = d
+		queue = append(queue, id)
+		return nil
+	}
+
+	for _, id := range opts.Roots {
+		if err := addItem(id, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	var seenEdges = make(map[[3]string]struct{})
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		related, err := relations(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get relations of %s: %w", id, err)
+		}
+
+		for _, rel := range related {
+			if !slices.Contains(followed, rel.Type) {
+				continue
+			}
+
+			if depth[id] < opts.Depth {
+				if err := addItem(rel.ItemID, depth[id]+1); err != nil {
+					return nil, err
+				}
+			}
+			if _, ok := depth[rel.ItemID]; !ok {
+				continue
+			}
+
+			label, flipped := edgeDirection(rel.Type)
+			source, target := id, rel.ItemID
+			if flipped {
+				source, target = target, source
+			}
+
+			// Both items report the relation, and relates_to has no direction
+			key := [3]string{source, target, string(label)}
+			if label == meili.RelationRelatesTo && target < source {
+				key = [3]string{target, source, string(label)}
+			}
+			if _, ok := seenEdges[key]; ok {
+				continue
+			}
+			seenEdges[key] = struct{}{}
+
+			edge := &Edge{
+				Source: source,
+				Target: target,
+				Label:  string(label),
+			}
+			if label != meili.RelationRelatesTo {
+				edge.MarkerEnd = "arrowclosed"
+			}
+			if err := g.AddEdge(edge); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	g.layoutLayered()
+
+	return g, nil
+}
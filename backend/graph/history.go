@@ -0,0 +1,312 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrVersionNotFound  = errors.New("version not found")
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+)
+
+// Snapshot is a named version of a graph. It keeps a copy of the graph, so it can be restored
+// even if the change log doesn't reach back that far
+type Snapshot struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Version   uint64    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Graph *Graph `json:"graph,omitempty"`
+}
+
+const (
+	// historyCheckpointInterval is how many operations are logged before the whole graph is logged again as a base record,
+	// so rebuilding a version never replays more than that
+	historyCheckpointInterval = 1000
+	// historyLimit is how many versions back the change log reaches at least. Older records are dropped
+	historyLimit = 10000
+)
+
+// lastHistoryVersion returns the graph version the records end with
+func lastHistoryVersion(records []HistoryRecord) (version uint64, ok bool) {
+	if len(records) == 0 {
+		return 0, false
+	}
+	last := records[len(records)-1]
+	if last.Base != nil {
+		return last.Base.Version, true
+	}
+	return last.Operation.Version, true
+}
+
+// initHistory makes sure the change log of the graph ends with its current version. Graphs created before
+// history was recorded, or whose last change was not logged because of a crash, get a new base record
+func (m *Manager) initHistory(g *Graph) error {
+	records, err := m.storage.LoadHistory(g.ID, g.Version)
+	if err != nil {
+		return err
+	}
+
+	if version, ok := lastHistoryVersion(records); ok && version == g.Version {
+		for _, r := range records {
+			if r.Operation != nil {
+				g.sinceCheckpoint++
+			}
+		}
+		return nil
+	}
+
+	return m.storage.AppendHistory(g.ID, HistoryRecord{Time: g.UpdatedAt, Base: g.Clone()})
+}
+
+// checkpoint logs the whole graph as a base record and drops the records older than historyLimit versions.
+// Callers must hold the write lock
+func (m *Manager) checkpoint(g *Graph) error {
+	if err := m.storage.AppendHistory(g.ID, HistoryRecord{Time: g.UpdatedAt, Base: g.Clone()}); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	g.sinceCheckpoint = 0
+
+	if g.Version > historyLimit {
+		if err := m.storage.CompactHistory(g.ID, g.Version-historyLimit); err != nil {
+			return fmt.Errorf("failed to compact history: %w", err)
+		}
+	}
+	return nil
+}
+
+// History returns the operations recorded for the graph, oldest first. It reaches at least historyLimit versions back
+func (m *Manager) History(id string) ([]HistoryRecord, error) {
+	if _, err := m.Get(id); err != nil {
+		return nil, err
+	}
+
+	records, err := m.storage.LoadHistory(id, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops = make([]HistoryRecord, 0, len(records))
+	for _, r := range records {
+		if r.Operation != nil {
+			ops = append(ops, r)
+		}
+	}
+	return ops, nil
+}
+
+// AtVersion returns a copy of the graph as it was at the given version. It is rebuilt from the change log
+// by replaying the operations after the newest base record before the version, or taken from a snapshot
+func (m *Manager) AtVersion(id string, version uint64) (*Graph, error) {
+	var current *Graph
+	err := m.View(id, func(g *Graph) error {
+		if version == g.Version {
+			current = g.Clone()
+		} else if version > g.Version {
+			return fmt.Errorf("%w: the graph is at version %d", ErrVersionNotFound, g.Version)
+		}
+		return nil
+	})
+	if err != nil || current != nil {
+		return current, err
+	}
+
+	return m.rebuild(id, version)
+}
+
+// rebuild returns a copy of the graph at an earlier version from its change log or snapshots.
+// It only reads storage, from the base record before the version on, so it can be used while holding the graph's lock
+func (m *Manager) rebuild(id string, version uint64) (*Graph, error) {
+	records, err := m.storage.LoadHistory(id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// The operations following a base describe the changes after it. A later base with a lower
+	// version means the operations before it were lost in a crash and are not part of the graph anymore
+	start := -1
+	for i, r := range records {
+		if r.Base != nil && r.Base.Version <= version {
+			start = i
+		}
+	}
+
+	if start < 0 {
+		return m.snapshotAtVersion(id, version)
+	}
+
+	g := records[start].Base.Clone()
+	for _, r := range records[start+1:] {
+		if r.Base != nil {
+			break
+		}
+		if r.Operation.Version <= g.Version {
+			continue
+		}
+		if r.Operation.Version > version {
+			break
+		}
+
+		if err := g.Apply(r.Operation); err != nil {
+			return nil, fmt.Errorf("failed to replay version %d: %w", r.Operation.Version, err)
+		}
+		g.Version = r.Operation.Version
+		g.UpdatedAt = r.Time
+	}
+
+	if g.Version != version {
+		return m.snapshotAtVersion(id, version)
+	}
+	return g, nil
+}
+
+func (m *Manager) snapshotAtVersion(id string, version uint64) (*Graph, error) {
+	snapshots, err := m.storage.LoadSnapshots(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snapshots {
+		if s.Version == version && s.Graph != nil {
+			return s.Graph, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: version %d is not recorded", ErrVersionNotFound, version)
+}
+
+// Restore brings the graph back to the given version. The differences are applied as operations,
+// so viewers see the restore like any other change and it is recorded as a new version itself
+func (m *Manager) Restore(id string, version uint64, clientID string, userID int) ([]*Operation, error) {
+	target, err := m.AtVersion(id, version)
+	if err != nil {
+		return nil, err
+	}
+	return m.restore(id, target, clientID, userID)
+}
+
+// RestoreSnapshot brings the graph back to the state saved in a named snapshot
+func (m *Manager) RestoreSnapshot(id, snapshotID string, clientID string, userID int) ([]*Operation, error) {
+	snapshot, err := m.Snapshot(id, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	return m.restore(id, snapshot.Graph, clientID, userID)
+}
+
+func (m *Manager) restore(id string, target *Graph, clientID string, userID int) ([]*Operation, error) {
+	return m.ApplyComputed(id, func(g *Graph) ([]*Operation, error) {
+		ops := DiffOperations(g, target)
+		for _, op := range ops {
+			op.ClientID = clientID
+			op.UserID = userID
+		}
+		return ops, nil
+	})
+}
+
+// Snapshots returns the named snapshots of the graph without their graph copies, oldest first
+func (m *Manager) Snapshots(id string) ([]*Snapshot, error) {
+	var snapshots []*Snapshot
+	err := m.View(id, func(g *Graph) error {
+		var err error
+		snapshots, err = m.storage.LoadSnapshots(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range snapshots {
+		s.Graph = nil
+	}
+	if snapshots == nil {
+		snapshots = []*Snapshot{}
+	}
+	return snapshots, nil
+}
+
+// CreateSnapshot names the given version of the graph
+func (m *Manager) CreateSnapshot(id, name string, version uint64) (*Snapshot, error) {
+	g, err := m.AtVersion(id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Version:   version,
+		CreatedAt: time.Now(),
+		Graph:     g,
+	}
+
+	err = m.editSnapshots(id, func(snapshots []*Snapshot) ([]*Snapshot, error) {
+		return append(snapshots, snapshot), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		ID:        snapshot.ID,
+		Name:      snapshot.Name,
+		Version:   snapshot.Version,
+		CreatedAt: snapshot.CreatedAt,
+	}, nil
+}
+
+// Snapshot returns a named snapshot including its copy of the graph
+func (m *Manager) Snapshot(id, snapshotID string) (*Snapshot, error) {
+	var snapshot *Snapshot
+	err := m.View(id, func(g *Graph) error {
+		snapshots, err := m.storage.LoadSnapshots(id)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(snapshots, func(s *Snapshot) bool { return s.ID == snapshotID })
+		if i < 0 {
+			return ErrSnapshotNotFound
+		}
+		snapshot = snapshots[i]
+		return nil
+	})
+	return snapshot, err
+}
+
+func (m *Manager) DeleteSnapshot(id, snapshotID string) error {
+	return m.editSnapshots(id, func(snapshots []*Snapshot) ([]*Snapshot, error) {
+		i := slices.IndexFunc(snapshots, func(s *Snapshot) bool { return s.ID == snapshotID })
+		if i < 0 {
+			return nil, ErrSnapshotNotFound
+		}
+		return slices.Delete(snapshots, i, i+1), nil
+	})
+}
+
+// editSnapshots changes the snapshots of a graph while holding its write lock, so concurrent edits don't get lost
+func (m *Manager) editSnapshots(id string, fn func(snapshots []*Snapshot) ([]*Snapshot, error)) error {
+	g, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	snapshots, err := m.storage.LoadSnapshots(id)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err = fn(snapshots)
+	if err != nil {
+		return err
+	}
+
+	return m.storage.SaveSnapshots(id, snapshots)
+}
@@ -0,0 +1,307 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MaxImportedNodes bounds the size of imported graphs, as every label may have to be resolved
+const MaxImportedNodes = 1000
+
+type ImportFormat string
+
+const (
+	ImportMermaid ImportFormat = "mermaid"
+	// ImportCanvas is the JSON Canvas format used by Obsidian's .canvas files, see https://jsoncanvas.org
+	ImportCanvas ImportFormat = "canvas"
+)
+
+// ParseImportFormat parses the format name. If it is empty, the format is guessed from the data
+func ParseImportFormat(s string, data []byte) (ImportFormat, error) {
+	switch f := ImportFormat(strings.ToLower(s)); f {
+	case ImportMermaid, ImportCanvas:
+		return f, nil
+	case "mmd":
+		return ImportMermaid, nil
+	case "json", "jsoncanvas":
+		return ImportCanvas, nil
+	case "":
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			return ImportCanvas, nil
+		}
+		return ImportMermaid, nil
+	default:
+		return "", fmt.Errorf("%w: unknown import format %q", ErrInvalid, s)
+	}
+}
+
+// ResolveFunc returns the item ID a GitLab reference or web URL points to, or an empty string if ref isn't one.
+// It is called concurrently. Labels whose reference fails to resolve are imported as text
+type ResolveFunc func(ctx context.Context, ref string) (itemID string, err error)
+
+// referenceCandidate returns the part of a label that could be a GitLab reference. Exported graphs label
+// GitLab items like "group/project#12: Title (opened)", and pasted references or URLs are a single word
+func referenceCandidate(label string) string {
+	fields := strings.Fields(label)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(fields[0], ":")
+}
+
+// importResolveConcurrency bounds how many references of an imported file are resolved at the same time
+const importResolveConcurrency = 8
+
+// resolveReferences resolves the distinct reference candidates of the labels in parallel and returns the item IDs
+// by candidate. References that fail to resolve are left out, so their nodes are imported as text
+func resolveReferences(ctx context.Context, resolve ResolveFunc, labels []string) (map[string]string, error) {
+	var candidates = make(map[string]bool)
+	for _, label := range labels {
+		if ref := referenceCandidate(label); ref != "" {
+			candidates[ref] = true
+		}
+	}
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		sem   = make(chan struct{}, importResolveConcurrency)
+		items = make(map[string]string, len(candidates))
+	)
+	for ref := range candidates {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			itemID, err := resolve(ctx, ref)
+			if err != nil || itemID == "" {
+				return
+			}
+			mu.Lock()
+			items[ref] = itemID
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// importNode creates a GitLab item node if the text starts with a resolved reference, and a text node otherwise
+func importNode(items map[string]string, text string, position Position) *Node {
+	if itemID, ok := items[referenceCandidate(text)]; ok {
+		return &Node{Type: NodeTypeGitLabItem, ItemID: itemID, Position: position}
+	}
+	return &Node{Type: NodeTypeText, Content: text, Position: position}
+}
+
+// Import parses a Mermaid flowchart or JSON Canvas file into a new graph. Labels that start with a GitLab reference
+// or web URL become GitLab item nodes, all others and those that fail to resolve become text nodes. If name is empty, the diagram's title is used
+func Import(ctx context.Context, data []byte, format ImportFormat, name string, resolve ResolveFunc) (*Graph, error) {
+	var g *Graph
+	var err error
+	switch format {
+	case ImportMermaid:
+		g, err = importMermaid(ctx, string(data), resolve)
+	case ImportCanvas:
+		g, err = importCanvas(ctx, data, resolve)
+	default:
+		return nil, fmt.Errorf("%w: unknown import format %q", ErrInvalid, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if name = strings.TrimSpace(name); name != "" {
+		g.Name = name
+	}
+	if g.Name == "" {
+		g.Name = "Imported graph"
+	}
+
+	return g, nil
+}
+
+func importMermaid(ctx context.Context, src string, resolve ResolveFunc) (*Graph, error) {
+	chart, err := parseMermaid(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(chart.nodes) > MaxImportedNodes {
+		return nil, fmt.Errorf("%w: the flowchart has more than %d nodes", ErrInvalid, MaxImportedNodes)
+	}
+
+	g := &Graph{
+		Name:  chart.title,
+		Nodes: []*Node{},
+		Edges: []*Edge{},
+	}
+
+	var labels = make([]string, len(chart.nodes))
+	for i, mn := range chart.nodes {
+		labels[i] = mn.label
+	}
+	items, err := resolveReferences(ctx, resolve, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mermaid IDs are only unique within the chart, so nodes get new IDs
+	var nodeIDs = make(map[string]string, len(chart.nodes))
+	for _, mn := range chart.nodes {
+		n := importNode(items, mn.label, Position{})
+		if err := g.AddNode(n); err != nil {
+			return nil, err
+		}
+		nodeIDs[mn.id] = n.ID
+	}
+
+	for _, me := range chart.edges {
+		e := &Edge{
+			Source: nodeIDs[me.source],
+			Target: nodeIDs[me.target],
+			Label:  me.label,
+		}
+		if me.directed {
+			e.MarkerEnd = "arrowclosed"
+		}
+		if err := g.AddEdge(e); err != nil {
+			return nil, err
+		}
+	}
+
+	// Flowcharts don't have positions, so they are laid out like Mermaid would
+	positions, err := g.Layout(LayoutOptions{Algorithm: LayoutLayered, Direction: chart.direction})
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range g.Nodes {
+		n.Position = positions[n.ID]
+	}
+
+	return g, nil
+}
+
+type canvasNode struct {
+	ID   string  `json:"id"`
+	Type string  `json:"type"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+
+	Text string `json:"text"`
+	File string `json:"file"`
+	URL  string `json:"url"`
+}
+
+// text returns the content a node is imported with. Groups only frame other nodes and aren't imported
+func (cn canvasNode) text() (string, bool) {
+	switch cn.Type {
+	case "text":
+		return cn.Text, true
+	case "link":
+		return cn.URL, true
+	case "file":
+		return cn.File, true
+	default:
+		return "", false
+	}
+}
+
+type canvasEdge struct {
+	ID       string `json:"id"`
+	FromNode string `json:"fromNode"`
+	ToNode   string `json:"toNode"`
+	FromEnd  string `json:"fromEnd"`
+	ToEnd    string `json:"toEnd"`
+	Label    string `json:"label"`
+}
+
+type canvasDocument struct {
+	Nodes []canvasNode `json:"nodes"`
+	Edges []canvasEdge `json:"edges"`
+}
+
+func importCanvas(ctx context.Context, data []byte, resolve ResolveFunc) (*Graph, error) {
+	var doc canvasDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: invalid JSON Canvas: %v", ErrInvalid, err)
+	}
+	if len(doc.Nodes) > MaxImportedNodes {
+		return nil, fmt.Errorf("%w: the canvas has more than %d nodes", ErrInvalid, MaxImportedNodes)
+	}
+
+	g := &Graph{
+		Nodes: []*Node{},
+		Edges: []*Edge{},
+	}
+
+	var labels []string
+	for _, cn := range doc.Nodes {
+		if text, ok := cn.text(); ok {
+			labels = append(labels, text)
+		}
+	}
+	items, err := resolveReferences(ctx, resolve, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeIDs = make(map[string]string, len(doc.Nodes))
+	for _, cn := range doc.Nodes {
+		text, ok := cn.text()
+		if !ok {
+			continue
+		}
+
+		n := importNode(items, text, Position{X: cn.X, Y: cn.Y})
+		if err := g.AddNode(n); err != nil {
+			return nil, err
+		}
+		nodeIDs[cn.ID] = n.ID
+	}
+
+	for _, ce := range doc.Edges {
+		source, ok := nodeIDs[ce.FromNode]
+		if !ok {
+			continue
+		}
+		target, ok := nodeIDs[ce.ToNode]
+		if !ok {
+			continue
+		}
+
+		// Canvas edges point to their target unless toEnd says otherwise, and can also point backwards
+		if ce.FromEnd == "arrow" && ce.ToEnd == "none" {
+			source, target = target, source
+		}
+		e := &Edge{
+			Source: source,
+			Target: target,
+			Label:  ce.Label,
+		}
+		if ce.ToEnd != "none" || ce.FromEnd == "arrow" {
+			e.MarkerEnd = "arrowclosed"
+		}
+		if err := g.AddEdge(e); err != nil {
+			return nil, err
+		}
+	}
+
+	return g, nil
+}
@@ -0,0 +1,143 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// resolveTestReferences resolves group/project#1 and leaves everything else as text
+func resolveTestReferences(_ context.Context, ref string) (string, error) {
+	if ref == "group/project#1" {
+		return "i1", nil
+	}
+	return "", nil
+}
+
+func TestImportCanvas(t *testing.T) {
+	canvas := `{
+		"nodes": [
+			{"id": "a", "type": "text", "text": "A", "x": 10, "y": 20},
+			{"id": "b", "type": "text", "text": "B"},
+			{"id": "item", "type": "text", "text": "group/project#1: Title (opened)"},
+			{"id": "link", "type": "link", "url": "https://example.com"},
+			{"id": "file", "type": "file", "file": "notes.md"},
+			{"id": "group", "type": "group", "label": "Frame"}
+		],
+		"edges": [
+			{"id": "default", "fromNode": "a", "toNode": "b", "label": "default"},
+			{"id": "both", "fromNode": "a", "toNode": "b", "fromEnd": "arrow", "toEnd": "arrow", "label": "both"},
+			{"id": "undirected", "fromNode": "a", "toNode": "b", "toEnd": "none", "label": "undirected"},
+			{"id": "backwards", "fromNode": "a", "toNode": "b", "fromEnd": "arrow", "toEnd": "none", "label": "backwards"},
+			{"id": "explicit", "fromNode": "a", "toNode": "b", "fromEnd": "none", "toEnd": "arrow", "label": "explicit"},
+			{"id": "to group", "fromNode": "a", "toNode": "group", "label": "to group"},
+			{"id": "dangling", "fromNode": "a", "toNode": "missing", "label": "dangling"}
+		]
+	}`
+
+	g, err := importCanvas(context.Background(), []byte(canvas), resolveTestReferences)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nodes get new IDs, so they are found by their content
+	var byText = make(map[string]*Node)
+	for _, n := range g.Nodes {
+		if n.Type == NodeTypeGitLabItem {
+			byText[n.ItemID] = n
+		} else {
+			byText[n.Content] = n
+		}
+	}
+	if len(g.Nodes) != 5 {
+		t.Errorf("got %d nodes, want 5 without the group", len(g.Nodes))
+	}
+	for _, text := range []string{"A", "B", "i1", "https://example.com", "notes.md"} {
+		if byText[text] == nil {
+			t.Errorf("missing node %q", text)
+		}
+	}
+	if a := byText["A"]; a != nil && a.Position != (Position{X: 10, Y: 20}) {
+		t.Errorf("got position %v, want the canvas position", a.Position)
+	}
+
+	a, b := byText["A"].ID, byText["B"].ID
+	for _, tt := range []struct {
+		label          string
+		source, target string
+		markerEnd      string
+	}{
+		{label: "default", source: a, target: b, markerEnd: "arrowclosed"},
+		{label: "both", source: a, target: b, markerEnd: "arrowclosed"},
+		{label: "undirected", source: a, target: b},
+		{label: "backwards", source: b, target: a, markerEnd: "arrowclosed"},
+		{label: "explicit", source: a, target: b, markerEnd: "arrowclosed"},
+	} {
+		var edge *Edge
+		for _, e := range g.Edges {
+			if e.Label == tt.label {
+				edge = e
+			}
+		}
+		if edge == nil {
+			t.Errorf("%s: edge is missing", tt.label)
+			continue
+		}
+		if edge.Source != tt.source || edge.Target != tt.target {
+			t.Errorf("%s: got edge %s -> %s, want %s -> %s", tt.label, edge.Source, edge.Target, tt.source, tt.target)
+		}
+		if edge.MarkerEnd != tt.markerEnd {
+			t.Errorf("%s: got marker %q, want %q", tt.label, edge.MarkerEnd, tt.markerEnd)
+		}
+	}
+	if len(g.Edges) != 5 {
+		t.Errorf("got %d edges, want 5 without the ones to the group and missing nodes", len(g.Edges))
+	}
+}
+
+func TestImportCanvasInvalid(t *testing.T) {
+	if _, err := importCanvas(context.Background(), []byte(`{"nodes": [`), resolveTestReferences); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestImportResolveFailures(t *testing.T) {
+	var mu sync.Mutex
+	var calls = make(map[string]int)
+	resolve := func(_ context.Context, ref string) (string, error) {
+		mu.Lock()
+		calls[ref]++
+		mu.Unlock()
+
+		if ref == "group/project#2" {
+			return "", errors.New("GitLab is down")
+		}
+		return resolveTestReferences(context.Background(), ref)
+	}
+
+	g, err := importMermaid(context.Background(), `flowchart TD
+		A[group/project#1: First] --> B[group/project#2: Second]
+		C[group/project#1] --> D[Text]`, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items, texts int
+	for _, n := range g.Nodes {
+		switch {
+		case n.Type == NodeTypeGitLabItem && n.ItemID == "i1":
+			items++
+		case n.Type == NodeTypeText:
+			texts++
+		}
+	}
+	if items != 2 || texts != 2 {
+		t.Errorf("got %d item and %d text nodes, want 2 of each", items, texts)
+	}
+	for ref, n := range calls {
+		if n != 1 {
+			t.Errorf("%s was resolved %d times, want once", ref, n)
+		}
+	}
+}
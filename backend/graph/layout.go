@@ -0,0 +1,514 @@
+package graph
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// DefaultLayerSpacing is the distance between two layers of a layered layout
+	DefaultLayerSpacing = 200
+	// DefaultNodeSpacing is the distance between two nodes in the same layer, and the ideal edge length of a force layout
+	DefaultNodeSpacing = 320
+
+	// crossingSweeps is the number of down and up sweeps that reorder layers to reduce edge crossings
+	crossingSweeps = 12
+	// forceIterations is the number of simulation steps of a force-directed layout
+	forceIterations = 300
+	// maxForceNodes limits force-directed layouts, as every step compares all pairs of nodes.
+	// Larger graphs get a layered layout, which handles cycles as well
+	maxForceNodes = 1000
+	// forceGravity pulls nodes towards the center, so unconnected parts of a graph don't drift apart
+	forceGravity = 0.1
+)
+
+type LayoutAlgorithm string
+
+const (
+	// LayoutAuto uses a layered layout for acyclic graphs and a force-directed layout otherwise, up to maxForceNodes nodes
+	LayoutAuto    LayoutAlgorithm = ""
+	LayoutLayered LayoutAlgorithm = "layered"
+	LayoutForce   LayoutAlgorithm = "force"
+)
+
+// LayoutDirection is the direction edges point to in a layered layout
+type LayoutDirection string
+
+const (
+	LayoutTopBottom LayoutDirection = "TB"
+	LayoutBottomTop LayoutDirection = "BT"
+	LayoutLeftRight LayoutDirection = "LR"
+	LayoutRightLeft LayoutDirection = "RL"
+)
+
+type LayoutOptions struct {
+	Algorithm LayoutAlgorithm
+	// Direction defaults to LayoutTopBottom
+	Direction LayoutDirection
+
+	// NodeSpacing and LayerSpacing default to DefaultNodeSpacing and DefaultLayerSpacing
+	NodeSpacing  float64
+	LayerSpacing float64
+
+	// NodeIDs restricts the layout to these nodes, all nodes are laid out if it is empty.
+	// Only edges between the selected nodes are considered, and the result stays where the selection was
+	NodeIDs []string
+}
+
+func (o *LayoutOptions) validate() error {
+	switch o.Algorithm {
+	case LayoutAuto, LayoutLayered, LayoutForce:
+	default:
+		return fmt.Errorf("%w: unknown layout algorithm %q", ErrInvalid, o.Algorithm)
+	}
+
+	switch o.Direction {
+	case "":
+		o.Direction = LayoutTopBottom
+	case LayoutTopBottom, LayoutBottomTop, LayoutLeftRight, LayoutRightLeft:
+	default:
+		return fmt.Errorf("%w: unknown layout direction %q", ErrInvalid, o.Direction)
+	}
+
+	if o.NodeSpacing < 0 || o.LayerSpacing < 0 {
+		return fmt.Errorf("%w: spacing must not be negative", ErrInvalid)
+	}
+	if o.NodeSpacing == 0 {
+		o.NodeSpacing = DefaultNodeSpacing
+	}
+	if o.LayerSpacing == 0 {
+		o.LayerSpacing = DefaultLayerSpacing
+	}
+
+	return nil
+}
+
+// layoutGraph is the part of a graph a layout is computed for
+type layoutGraph struct {
+	nodes []*Node
+	// edges only contains edges between nodes, without self loops
+	edges [][2]string
+}
+
+func (g *Graph) layoutGraph(nodeIDs []string) (*layoutGraph, error) {
+	lg := &layoutGraph{}
+
+	var selected = make(map[string]bool, len(g.Nodes))
+	if len(nodeIDs) == 0 {
+		lg.nodes = g.Nodes
+	} else {
+		for _, id := range nodeIDs {
+			n := g.Node(id)
+			if n == nil {
+				return nil, fmt.Errorf("%w: %q", ErrNodeNotFound, id)
+			}
+			if !selected[id] {
+				lg.nodes = append(lg.nodes, n)
+			}
+			selected[id] = true
+		}
+	}
+	for _, n := range lg.nodes {
+		selected[n.ID] = true
+	}
+
+	for _, e := range g.Edges {
+		if e.Source != e.Target && selected[e.Source] && selected[e.Target] {
+			lg.edges = append(lg.edges, [2]string{e.Source, e.Target})
+		}
+	}
+
+	return lg, nil
+}
+
+// Layout computes new positions for the selected nodes without changing the graph. Callers must hold at least a read lock
+func (g *Graph) Layout(opts LayoutOptions) (map[string]Position, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	lg, err := g.layoutGraph(opts.NodeIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(lg.nodes) == 0 {
+		return map[string]Position{}, nil
+	}
+
+	algorithm := opts.Algorithm
+	switch {
+	case algorithm == LayoutForce && len(lg.nodes) > maxForceNodes:
+		return nil, fmt.Errorf("%w: force layouts are limited to %d nodes, got %d", ErrInvalid, maxForceNodes, len(lg.nodes))
+	case algorithm == LayoutAuto:
+		if len(lg.nodes) > maxForceNodes || len(lg.cycleEdges()) == 0 {
+			algorithm = LayoutLayered
+		} else {
+			algorithm = LayoutForce
+		}
+	}
+
+	var positions map[string]Position
+	if algorithm == LayoutLayered {
+		positions = lg.layered(opts)
+	} else {
+		positions = lg.force(opts)
+	}
+
+	// A partial layout replaces the selection in place, the whole graph is anchored at the origin
+	var anchor Position
+	if len(opts.NodeIDs) > 0 {
+		anchor = topLeft(lg.nodes, func(n *Node) Position { return n.Position })
+	}
+	origin := topLeft(lg.nodes, func(n *Node) Position { return positions[n.ID] })
+	for id, p := range positions {
+		positions[id] = Position{
+			X: math.Round(p.X - origin.X + anchor.X),
+			Y: math.Round(p.Y - origin.Y + anchor.Y),
+		}
+	}
+
+	return positions, nil
+}
+
+func topLeft(nodes []*Node, position func(n *Node) Position) Position {
+	var p = Position{X: math.Inf(1), Y: math.Inf(1)}
+	for _, n := range nodes {
+		p.X = min(p.X, position(n).X)
+		p.Y = min(p.Y, position(n).Y)
+	}
+	return p
+}
+
+// cycleEdges returns the edges that close a cycle when the graph is searched depth-first from its nodes in order.
+// Removing them makes the graph acyclic
+func (lg *layoutGraph) cycleEdges() map[[2]string]bool {
+	var outgoing = make(map[string][]string, len(lg.nodes))
+	for _, e := range lg.edges {
+		outgoing[e[0]] = append(outgoing[e[0]], e[1])
+	}
+
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	var state = make(map[string]int, len(lg.nodes))
+	var back = make(map[[2]string]bool)
+
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = active
+		for _, target := range outgoing[id] {
+			switch state[target] {
+			case unvisited:
+				visit(target)
+			case active:
+				back[[2]string{id, target}] = true
+			}
+		}
+		state[id] = done
+	}
+	for _, n := range lg.nodes {
+		if state[n.ID] == unvisited {
+			visit(n.ID)
+		}
+	}
+
+	return back
+}
+
+// layerNode is a node in a layer of a layered layout. Dummy nodes route edges that span more than one layer
+type layerNode struct {
+	id    string
+	dummy bool
+	// up and down are the neighbors in the previous and next layer
+	up, down []*layerNode
+
+	x     float64
+	order int
+}
+
+// layers assigns every node a layer using longest path layering, so that edges point from lower to higher layers.
+// Edges that close a cycle are reversed
+func (lg *layoutGraph) layers() [][]*layerNode {
+	back := lg.cycleEdges()
+
+	var outgoing = make(map[string][]string, len(lg.nodes))
+	var indegree = make(map[string]int, len(lg.nodes))
+	var edges = make([][2]string, 0, len(lg.edges))
+	var seen = make(map[[2]string]bool, len(lg.edges))
+	for _, e := range lg.edges {
+		if back[e] {
+			e = [2]string{e[1], e[0]}
+		}
+		// Parallel edges would only add dummy nodes
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+		edges = append(edges, e)
+		outgoing[e[0]] = append(outgoing[e[0]], e[1])
+		indegree[e[1]]++
+	}
+
+	// Kahn's algorithm keeps the node order stable for nodes without dependencies
+	var layer = make(map[string]int, len(lg.nodes))
+	var queue []string
+	for _, n := range lg.nodes {
+		if indegree[n.ID] == 0 {
+			queue = append(queue, n.ID)
+		}
+	}
+	var maxLayer int
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, target := range outgoing[id] {
+			layer[target] = max(layer[target], layer[id]+1)
+			maxLayer = max(maxLayer, layer[target])
+			indegree[target]--
+			if indegree[target] == 0 {
+				queue = append(queue, target)
+			}
+		}
+	}
+
+	var layers = make([][]*layerNode, maxLayer+1)
+	var byID = make(map[string]*layerNode, len(lg.nodes))
+	for _, n := range lg.nodes {
+		ln := &layerNode{id: n.ID}
+		byID[n.ID] = ln
+		layers[layer[n.ID]] = append(layers[layer[n.ID]], ln)
+	}
+
+	for _, e := range edges {
+		from := byID[e[0]]
+		for l := layer[e[0]] + 1; l < layer[e[1]]; l++ {
+			dummy := &layerNode{dummy: true}
+			layers[l] = append(layers[l], dummy)
+			from.down = append(from.down, dummy)
+			dummy.up = append(dummy.up, from)
+			from = dummy
+		}
+		to := byID[e[1]]
+		from.down = append(from.down, to)
+		to.up = append(to.up, from)
+	}
+
+	for _, l := range layers {
+		for i, n := range l {
+			n.order = i
+		}
+	}
+
+	return layers
+}
+
+// barycenter is the average order of the neighbors, or ok = false if there are none
+func barycenter(neighbors []*layerNode) (float64, bool) {
+	if len(neighbors) == 0 {
+		return 0, false
+	}
+	var sum float64
+	for _, n := range neighbors {
+		sum += float64(n.order)
+	}
+	return sum / float64(len(neighbors)), true
+}
+
+// reduceCrossings reorders the nodes within each layer by the barycenter of their neighbors,
+// sweeping down and up through the layers
+func reduceCrossings(layers [][]*layerNode) {
+	var reorder = func(layer []*layerNode, neighbors func(n *layerNode) []*layerNode) {
+		var keys = make(map[*layerNode]float64, len(layer))
+		for _, n := range layer {
+			if b, ok := barycenter(neighbors(n)); ok {
+				keys[n] = b
+			} else {
+				// Nodes without neighbors keep their place
+				keys[n] = float64(n.order)
+			}
+		}
+		sort.SliceStable(layer, func(i, j int) bool {
+			return keys[layer[i]] < keys[layer[j]]
+		})
+		for i, n := range layer {
+			n.order = i
+		}
+	}
+
+	for sweep := 0; sweep < crossingSweeps; sweep++ {
+		if sweep%2 == 0 {
+			for i := 1; i < len(layers); i++ {
+				reorder(layers[i], func(n *layerNode) []*layerNode { return n.up })
+			}
+		} else {
+			for i := len(layers) - 2; i >= 0; i-- {
+				reorder(layers[i], func(n *layerNode) []*layerNode { return n.down })
+			}
+		}
+	}
+}
+
+// assignCoordinates places nodes within their layers close to their neighbors while keeping the order and spacing
+func assignCoordinates(layers [][]*layerNode, spacing float64) {
+	for _, layer := range layers {
+		offset := -float64(len(layer)-1) * spacing / 2
+		for i, n := range layer {
+			n.x = offset + float64(i)*spacing
+		}
+	}
+
+	var align = func(layer []*layerNode, neighbors func(n *layerNode) []*layerNode) {
+		var desired = make([]float64, len(layer))
+		for i, n := range layer {
+			desired[i] = n.x
+			if nb := neighbors(n); len(nb) > 0 {
+				var sum float64
+				for _, m := range nb {
+					sum += m.x
+				}
+				desired[i] = sum / float64(len(nb))
+			}
+		}
+
+		// Push nodes apart from left to right, then shift the layer so it stays centered on the desired positions
+		var placed = make([]float64, len(layer))
+		var shift float64
+		for i := range layer {
+			placed[i] = desired[i]
+			if i > 0 {
+				placed[i] = max(placed[i], placed[i-1]+spacing)
+			}
+			shift += placed[i] - desired[i]
+		}
+		shift /= float64(len(layer))
+		for i, n := range layer {
+			n.x = placed[i] - shift
+		}
+	}
+
+	for pass := 0; pass < 4; pass++ {
+		for i := 1; i < len(layers); i++ {
+			align(layers[i], func(n *layerNode) []*layerNode { return n.up })
+		}
+		for i := len(layers) - 2; i >= 0; i-- {
+			align(layers[i], func(n *layerNode) []*layerNode { return n.down })
+		}
+	}
+}
+
+// layered computes a Sugiyama-style layout: layer assignment, crossing reduction and coordinate assignment
+func (lg *layoutGraph) layered(opts LayoutOptions) map[string]Position {
+	layers := lg.layers()
+	reduceCrossings(layers)
+	assignCoordinates(layers, opts.NodeSpacing)
+
+	var positions = make(map[string]Position, len(lg.nodes))
+	for l, layer := range layers {
+		for _, n := range layer {
+			if n.dummy {
+				continue
+			}
+
+			along := float64(l) * opts.LayerSpacing
+			switch opts.Direction {
+			case LayoutBottomTop:
+				positions[n.id] = Position{X: n.x, Y: -along}
+			case LayoutLeftRight:
+				positions[n.id] = Position{X: along, Y: n.x}
+			case LayoutRightLeft:
+				positions[n.id] = Position{X: -along, Y: n.x}
+			default:
+				positions[n.id] = Position{X: n.x, Y: along}
+			}
+		}
+	}
+
+	return positions
+}
+
+// force computes a Fruchterman-Reingold layout. It starts from a circle, so the result only depends on the graph
+func (lg *layoutGraph) force(opts LayoutOptions) map[string]Position {
+	k := opts.NodeSpacing
+	n := len(lg.nodes)
+
+	var pos = make([]Position, n)
+	var index = make(map[string]int, n)
+	radius := k * float64(n) / (2 * math.Pi)
+	for i, node := range lg.nodes {
+		index[node.ID] = i
+		angle := 2 * math.Pi * float64(i) / float64(n)
+		pos[i] = Position{X: radius * math.Cos(angle), Y: radius * math.Sin(angle)}
+	}
+
+	temperature := max(radius, k)
+	cooling := temperature / forceIterations
+
+	var disp = make([]Position, n)
+	for iter := 0; iter < forceIterations; iter++ {
+		for i := range disp {
+			disp[i] = Position{}
+		}
+
+		// Every pair of nodes repels each other
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				dx, dy := pos[i].X-pos[j].X, pos[i].Y-pos[j].Y
+				dist := math.Max(math.Hypot(dx, dy), 0.01)
+				f := k * k / dist
+				disp[i].X += dx / dist * f
+				disp[i].Y += dy / dist * f
+				disp[j].X -= dx / dist * f
+				disp[j].Y -= dy / dist * f
+			}
+		}
+
+		// Edges pull their ends together
+		for _, e := range lg.edges {
+			i, j := index[e[0]], index[e[1]]
+			dx, dy := pos[i].X-pos[j].X, pos[i].Y-pos[j].Y
+			dist := math.Max(math.Hypot(dx, dy), 0.01)
+			f := dist * dist / k
+			disp[i].X -= dx / dist * f
+			disp[i].Y -= dy / dist * f
+			disp[j].X += dx / dist * f
+			disp[j].Y += dy / dist * f
+		}
+
+		for i := range pos {
+			disp[i].X -= pos[i].X * forceGravity
+			disp[i].Y -= pos[i].Y * forceGravity
+		}
+
+		for i := range pos {
+			length := math.Hypot(disp[i].X, disp[i].Y)
+			if length == 0 {
+				continue
+			}
+			step := math.Min(length, temperature)
+			pos[i].X += disp[i].X / length * step
+			pos[i].Y += disp[i].Y / length * step
+		}
+
+		temperature = math.Max(temperature-cooling, 1)
+	}
+
+	var positions = make(map[string]Position, n)
+	for i, node := range lg.nodes {
+		positions[node.ID] = pos[i]
+	}
+	return positions
+}
+
+// layoutLayered positions all nodes with the default layered layout
+func (g *Graph) layoutLayered() {
+	positions, err := g.Layout(LayoutOptions{Algorithm: LayoutLayered})
+	if err != nil {
+		return
+	}
+	for _, n := range g.Nodes {
+		n.Position = positions[n.ID]
+	}
+}
@@ -1,9 +1,301 @@
 package graph
 
-import "sync"
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrGraphNotFound = errors.New("graph not found")
 
 type Manager struct {
 	lock sync.RWMutex
 
+	storage Storage
+
 	Graphs map[string]*Graph
 }
+
+// NewManager loads all graphs from storage. Every later change is written back to it
+func NewManager(storage Storage) (*Manager, error) {
+	graphs, err := storage.LoadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load graphs: %w", err)
+	}
+
+	m := &Manager{
+		storage: storage,
+		Graphs:  make(map[string]*Graph, len(graphs)),
+	}
+
+	for _, g := range graphs {
+		if err := m.initHistory(g); err != nil {
+			return nil, fmt.Errorf("failed to load history of graph %s: %w", g.ID, err)
+		}
+		m.Graphs[g.ID] = g
+	}
+
+	return m, nil
+}
+
+// Get returns the graph with the given ID
+func (m *Manager) Get(id string) (*Graph, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	g, ok := m.Graphs[id]
+	if !ok {
+		return nil, ErrGraphNotFound
+	}
+	return g, nil
+}
+
+// Summary describes a graph without its contents
+type Summary struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	NodeCount int `json:"node_count"`
+	EdgeCount int `json:"edge_count"`
+}
+
+// List returns summaries of all graphs, most recently updated first
+func (m *Manager) List() []Summary {
+	m.lock.RLock()
+	var summaries = make([]Summary, 0, len(m.Graphs))
+	for _, g := range m.Graphs {
+		g.lock.RLock()
+		summaries = append(summaries, Summary{
+			ID:        g.ID,
+			Name:      g.Name,
+			CreatedAt: g.CreatedAt,
+			UpdatedAt: g.UpdatedAt,
+			NodeCount: len(g.Nodes),
+			EdgeCount: len(g.Edges),
+		})
+		g.lock.RUnlock()
+	}
+	m.lock.RUnlock()
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt)
+	})
+
+	return summaries
+}
+
+// Create adds a new empty graph and persists it
+func (m *Manager) Create(name string) (*Graph, error) {
+	g := &Graph{
+		Name:  name,
+		Nodes: []*Node{},
+		Edges: []*Edge{},
+	}
+
+	if err := m.Add(g); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Add assigns a new ID to a graph built elsewhere, e.g. by Generate, and persists it
+func (m *Manager) Add(g *Graph) error {
+	now := time.Now()
+	g.ID = uuid.NewString()
+	g.CreatedAt = now
+	g.UpdatedAt = now
+
+	if err := m.storage.Save(g); err != nil {
+		return fmt.Errorf("failed to save graph: %w", err)
+	}
+	if err := m.storage.AppendHistory(g.ID, HistoryRecord{Time: g.UpdatedAt, Base: g.Clone()}); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+
+	m.lock.Lock()
+	m.Graphs[g.ID] = g
+	m.lock.Unlock()
+
+	return nil
+}
+
+// View runs fn with the graph read-locked. fn must not modify the graph
+func (m *Manager) View(id string, fn func(g *Graph) error) error {
+	g, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	return fn(g)
+}
+
+// update runs fn with the graph write-locked. fn returns the change it applied, whose operations are broadcast and
+// added to the graph's history once the graph is persisted. If fn fails or the change can't be saved, the graph is rolled back
+func (m *Manager) update(id string, fn func(g *Graph) (*change, error)) error {
+	g, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	saved, stacks := g.Clone(), g.cloneStacks()
+
+	c, err := fn(g)
+	if err != nil {
+		// Changes to the undo stacks are kept, e.g. a change that can't be undone anymore is dropped
+		g.restore(saved)
+		return err
+	}
+	if c == nil || len(c.ops) == 0 {
+		return nil
+	}
+
+	g.UpdatedAt = time.Now()
+
+	// The change log is written first, so every version that is saved and broadcast can be rebuilt from it
+	var records = make([]HistoryRecord, 0, len(c.ops))
+	for _, op := range c.ops {
+		records = append(records, HistoryRecord{Time: g.UpdatedAt, Operation: op, ClientEdit: op.clientEdit})
+	}
+	if err := m.storage.AppendHistory(g.ID, records...); err != nil {
+		g.restore(saved)
+		g.undo = stacks
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	g.sinceCheckpoint += len(records)
+
+	if err := m.storage.Save(g); err != nil {
+		g.restore(saved)
+		g.undo = stacks
+		// A base record with the earlier version marks the logged operations as discarded, like after a crash
+		if err := m.checkpoint(g); err != nil {
+			log.Printf("failed to checkpoint the history of graph %s: %v", g.ID, err)
+		}
+		return fmt.Errorf("failed to save graph: %w", err)
+	}
+
+	for _, op := range c.ops {
+		g.publish(op)
+	}
+
+	// The change is saved at this point, so a failed checkpoint is only retried with the next change
+	if g.sinceCheckpoint >= historyCheckpointInterval {
+		if err := m.checkpoint(g); err != nil {
+			log.Printf("failed to checkpoint the history of graph %s: %v", g.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// restore resets the graph to a copy taken before a change that failed. Callers must hold the write lock
+func (g *Graph) restore(saved *Graph) {
+	g.Name, g.UpdatedAt, g.Version = saved.Name, saved.UpdatedAt, saved.Version
+	g.Nodes, g.Edges = saved.Nodes, saved.Edges
+}
+
+// Delete removes the graph from memory and storage
+func (m *Manager) Delete(id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.Graphs[id]; !ok {
+		return ErrGraphNotFound
+	}
+
+	if err := m.storage.Delete(id); err != nil {
+		return err
+	}
+
+	g := m.Graphs[id]
+	delete(m.Graphs, id)
+
+	g.lock.Lock()
+	g.closeSubscribers()
+	g.lock.Unlock()
+
+	return nil
+}
+
+// Apply applies the operation to the graph, persists it and broadcasts it to all subscribers.
+// Operations on the same graph are applied and broadcast in a single order. The operation can be undone by its user.
+// If the graph changed since op.BaseVersion, the operation is merged with the changes first; if it is discarded
+// because of them, nil is returned and op.Version stays 0
+func (m *Manager) Apply(id string, op *Operation) error {
+	return m.update(id, func(g *Graph) (*change, error) {
+		keep, err := m.rebase(g, op)
+		if err != nil || !keep {
+			return nil, err
+		}
+
+		inverse, err := g.commit(op)
+		if err != nil {
+			return nil, err
+		}
+
+		c := &change{ops: []*Operation{op}, inverse: inverse}
+		g.record(c)
+		return c, nil
+	})
+}
+
+// ApplyComputed lets fn build operations from the current state of the graph and applies them like Apply,
+// without other changes in between. The graph is saved once and the operations are undone together.
+// If an operation fails, none of them are applied
+func (m *Manager) ApplyComputed(id string, fn func(g *Graph) ([]*Operation, error)) (applied []*Operation, err error) {
+	err = m.update(id, func(g *Graph) (*change, error) {
+		ops, err := fn(g)
+		if err != nil {
+			return nil, err
+		}
+
+		c := &change{}
+		for _, op := range ops {
+			inverse, err := g.commit(op)
+			if err != nil {
+				return nil, err
+			}
+			c.ops = append(c.ops, op)
+			c.inverse = append(inverse, c.inverse...)
+		}
+
+		g.record(c)
+		applied = c.ops
+		return c, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// commit applies the operation and assigns it the next version. It is broadcast by update once the graph is saved.
+// It returns the operations that revert it. Callers must hold the write lock
+func (g *Graph) commit(op *Operation) (inverse []*Operation, err error) {
+	if inverse, err = g.Inverse(op); err != nil {
+		return nil, err
+	}
+
+	if err := g.Apply(op); err != nil {
+		return nil, err
+	}
+
+	g.Version++
+	op.Version = g.Version
+
+	return inverse, nil
+}
@@ -0,0 +1,126 @@
+package graph
+
+import (
+	"errors"
+	"testing"
+)
+
+// failingStorage fails saving graphs or appending to their change log while the flags are set
+type failingStorage struct {
+	*FileStorage
+	failSave, failHistory bool
+}
+
+func (s *failingStorage) Save(g *Graph) error {
+	if s.failSave {
+		return errors.New("disk full")
+	}
+	return s.FileStorage.Save(g)
+}
+
+func (s *failingStorage) AppendHistory(id string, records ...HistoryRecord) error {
+	if s.failHistory {
+		return errors.New("disk full")
+	}
+	return s.FileStorage.AppendHistory(id, records...)
+}
+
+func TestFailedChangesAreRolledBack(t *testing.T) {
+	files, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := &failingStorage{FileStorage: files}
+	m, err := NewManager(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newTestGraph(t, m, "a")
+
+	sub, err := m.Subscribe(g.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	for _, tt := range []struct {
+		name                  string
+		failSave, failHistory bool
+	}{
+		{name: "history", failHistory: true},
+		{name: "save", failSave: true},
+	} {
+		storage.failSave, storage.failHistory = tt.failSave, tt.failHistory
+		err := m.Apply(g.ID, &Operation{Type: OpAddNode, UserID: 1, Node: &Node{ID: "c", Type: NodeTypeText}})
+		if err == nil {
+			t.Fatalf("%s: expected an error", tt.name)
+		}
+
+		_ = m.View(g.ID, func(g *Graph) error {
+			if g.Version != 3 || g.Node("c") != nil {
+				t.Errorf("%s: got version %d with node c %v, want the graph before the change", tt.name, g.Version, g.Node("c"))
+			}
+			return nil
+		})
+		if undo, _, _ := m.UndoDepth(g.ID, 1); undo != 0 {
+			t.Errorf("%s: the failed change can be undone", tt.name)
+		}
+		select {
+		case op := <-sub.Operations():
+			t.Errorf("%s: the failed change was broadcast as version %d", tt.name, op.Version)
+		default:
+		}
+	}
+
+	// The next change gets the version the failed ones had and can be rebuilt from the change log
+	storage.failSave, storage.failHistory = false, false
+	mustApply(t, m, g.ID, &Operation{Type: OpAddNode, Node: &Node{ID: "d", Type: NodeTypeText, Content: "d"}})
+	mustApply(t, m, g.ID, &Operation{Type: OpUpdateNode, NodeID: "d", Content: ptr("e")})
+
+	at, err := m.AtVersion(g.ID, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := at.Node("d"); n == nil || n.Content != "d" || at.Node("c") != nil {
+		t.Errorf("got nodes %v at version 4, want d without c", at.Nodes)
+	}
+}
+
+func TestApplyComputedIsAllOrNothing(t *testing.T) {
+	m := newTestManager(t)
+	g := newTestGraph(t, m, "a")
+
+	sub, err := m.Subscribe(g.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	applied, err := m.ApplyComputed(g.ID, func(g *Graph) ([]*Operation, error) {
+		return []*Operation{
+			{Type: OpUpdateNode, NodeID: "a", Content: ptr("changed"), UserID: 1},
+			{Type: OpUpdateNode, NodeID: "missing", Content: ptr("x"), UserID: 1},
+		}, nil
+	})
+	if !errors.Is(err, ErrNodeNotFound) || applied != nil {
+		t.Fatalf("got %v and %d operations, want ErrNodeNotFound and none", err, len(applied))
+	}
+
+	if c := content(t, m, g.ID, "a"); c != "a" {
+		t.Errorf("got content %q, want the first operation rolled back", c)
+	}
+	_ = m.View(g.ID, func(g *Graph) error {
+		if g.Version != 3 {
+			t.Errorf("got version %d, want 3", g.Version)
+		}
+		return nil
+	})
+	if undo, _, _ := m.UndoDepth(g.ID, 1); undo != 0 {
+		t.Error("the failed change can be undone")
+	}
+	select {
+	case op := <-sub.Operations():
+		t.Errorf("version %d of the failed change was broadcast", op.Version)
+	default:
+	}
+}
@@ -0,0 +1,196 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Concurrent edits are merged on the server, which applies all operations of a graph in a single order: an operation made
+// at an older BaseVersion is transformed against the operations applied since, then applied and broadcast like any other.
+// Every client applies the broadcast operations in version order, so all of them converge on the server's graph.
+//
+// The rules are:
+//   - Text content edits are transformed against concurrent edits of the same node, so both are kept.
+//     Text inserted at the same position by an earlier applied operation comes first
+//   - Positions, edges and the graph name are replaced as a whole, the operation applied last wins
+//   - Operations on nodes and edges that were removed concurrently are discarded
+//   - Adding a node or edge that was already added with the same ID and contents, e.g. because an offline client
+//     replayed it twice, is discarded
+//
+// Operations from the same client are assumed to be sent in order and made on top of each other, so a client's own earlier
+// operations are not concurrent to it. Its edits sent with the same BaseVersion are merged the way the client applied them.
+
+// operationsSince returns the operations applied after the version, oldest first. ok is false if they are
+// no longer all available, e.g. because the change log was incomplete after a crash. Callers must hold the write lock
+func (m *Manager) operationsSince(g *Graph, version uint64) (ops []*Operation, ok bool, err error) {
+	if len(g.log) > 0 && g.log[0].Version <= version+1 {
+		for _, op := range g.log {
+			if op.Version > version {
+				ops = append(ops, op)
+			}
+		}
+		return ops, true, nil
+	}
+
+	records, err := m.storage.LoadHistory(g.ID, version)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, r := range records {
+		if r.Operation != nil && r.Operation.Version > version {
+			r.Operation.clientEdit = r.ClientEdit
+			ops = append(ops, r.Operation)
+		}
+	}
+
+	return ops, uint64(len(ops)) == g.Version-version, nil
+}
+
+// rebase transforms an operation made at op.BaseVersion so it can be applied to the current graph.
+// It returns false if the operation is discarded. Callers must hold the write lock
+func (m *Manager) rebase(g *Graph, op *Operation) (keep bool, err error) {
+	if op.BaseVersion > g.Version {
+		return false, fmt.Errorf("%w: base version %d is newer than the graph's version %d", ErrInvalid, op.BaseVersion, g.Version)
+	}
+	if op.BaseVersion == 0 || op.BaseVersion == g.Version {
+		return true, nil
+	}
+
+	switch op.Type {
+	case OpAddNode:
+		if op.Node != nil && op.Node.ID != "" {
+			if n := g.Node(op.Node.ID); n != nil && sameNode(n, op.Node) {
+				return false, nil
+			}
+		}
+	case OpUpdateNode:
+		if g.Node(op.NodeID) == nil {
+			return false, nil
+		}
+		if op.Content != nil || op.ContentEdit != nil {
+			return true, m.rebaseContent(g, op)
+		}
+	case OpRemoveNode:
+		if g.Node(op.NodeID) == nil {
+			return false, nil
+		}
+	case OpAddEdge, OpUpdateEdge:
+		if op.Edge == nil {
+			return true, nil
+		}
+		if op.Type == OpAddEdge && op.Edge.ID != "" {
+			if e := g.Edge(op.Edge.ID); e != nil && *e == *op.Edge {
+				return false, nil
+			}
+		}
+		if op.Type == OpUpdateEdge && g.Edge(op.Edge.ID) == nil {
+			return false, nil
+		}
+		if g.Node(op.Edge.Source) == nil || g.Node(op.Edge.Target) == nil {
+			return false, nil
+		}
+	case OpRemoveEdge:
+		if g.Edge(op.EdgeID) == nil {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// rebaseContent turns the content change of an update_node operation into an edit of the current content.
+// The operation is based on the text at its BaseVersion with the client's own later changes applied, so the concurrent
+// edits of other clients are first transformed against those, as they would have been on the client.
+// If concurrent changes can't be merged, e.g. because they were recorded before edits were, new content replaces
+// the current one, while an edit fails with ErrConflict
+func (m *Manager) rebaseContent(g *Graph, op *Operation) error {
+	unmergeable := func() error {
+		if op.ContentEdit != nil {
+			return fmt.Errorf("%w: the content of node %q can't be merged with the changes since version %d", ErrConflict, op.NodeID, op.BaseVersion)
+		}
+		return nil
+	}
+
+	concurrent, ok, err := m.operationsSince(g, op.BaseVersion)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return unmergeable()
+	}
+
+	// text is the content as the client had it, only needed to turn new content into an edit
+	var text string
+	if op.ContentEdit == nil {
+		base, err := m.rebuild(g.ID, op.BaseVersion)
+		if errors.Is(err, ErrVersionNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		n := base.Node(op.NodeID)
+		if n == nil {
+			return nil
+		}
+		text = n.Content
+	}
+
+	// others are the edits of other clients the sender hasn't seen yet, transformed to apply to its text
+	var others []TextEdit
+	for _, c := range concurrent {
+		switch {
+		case c.Type == OpAddNode && c.Node != nil && c.Node.ID == op.NodeID:
+			// The node was removed and added again, the edit is based on a different text
+			return unmergeable()
+		case c.Type != OpUpdateNode || c.NodeID != op.NodeID || c.Content == nil:
+			continue
+		case op.ClientID != "" && c.ClientID == op.ClientID:
+			if c.BaseVersion != op.BaseVersion {
+				// The client received other changes in between, which it merged on its own
+				return unmergeable()
+			}
+			if c.clientEdit == nil {
+				// The change was applied as is, so the client's text matched the graph's afterwards
+				others = nil
+				text = *c.Content
+				continue
+			}
+
+			own := c.clientEdit
+			if op.ContentEdit == nil {
+				if text, err = own.apply(text); err != nil {
+					return err
+				}
+			}
+			for i, other := range others {
+				if others[i], own, err = transformText(other, own); err != nil {
+					return err
+				}
+			}
+		default:
+			if c.ContentEdit == nil {
+				return unmergeable()
+			}
+			others = append(others, c.ContentEdit)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+
+	edit := op.ContentEdit
+	if edit == nil {
+		edit = diffText(text, *op.Content)
+	}
+	op.clientEdit = edit
+
+	for _, other := range others {
+		if _, edit, err = transformText(other, edit); err != nil {
+			return err
+		}
+	}
+
+	op.Content = nil
+	op.ContentEdit = edit
+	return nil
+}
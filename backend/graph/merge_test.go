@@ -0,0 +1,402 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+
+	storage, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewManager(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// newTestGraph creates a graph with a text node "a" with the given content, a text node "b" and an edge "ab" between them
+func newTestGraph(t *testing.T, m *Manager, content string) *Graph {
+	t.Helper()
+
+	g, err := m.Create("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range []*Operation{
+		{Type: OpAddNode, Node: &Node{ID: "a", Type: NodeTypeText, Content: content}},
+		{Type: OpAddNode, Node: &Node{ID: "b", Type: NodeTypeText, Content: "b"}},
+		{Type: OpAddEdge, Edge: &Edge{ID: "ab", Source: "a", Target: "b"}},
+	} {
+		mustApply(t, m, g.ID, op)
+	}
+	return g
+}
+
+func mustApply(t *testing.T, m *Manager, id string, op *Operation) {
+	t.Helper()
+
+	if err := m.Apply(id, op); err != nil {
+		t.Fatalf("failed to apply %s: %v", op.Type, err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func content(t *testing.T, m *Manager, id, nodeID string) string {
+	t.Helper()
+
+	var c string
+	err := m.View(id, func(g *Graph) error {
+		n := g.Node(nodeID)
+		if n == nil {
+			return ErrNodeNotFound
+		}
+		c = n.Content
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+var testAlphabet = []rune("ab c\näö")
+
+func randomText(r *rand.Rand, n int) string {
+	var b strings.Builder
+	for range n {
+		b.WriteRune(testAlphabet[r.Intn(len(testAlphabet))])
+	}
+	return b.String()
+}
+
+func randomEdit(r *rand.Rand, text string) TextEdit {
+	var b editBuilder
+	remaining := len([]rune(text))
+	for remaining > 0 {
+		n := 1 + r.Intn(remaining)
+		switch r.Intn(3) {
+		case 0:
+			b.retain(n)
+			remaining -= n
+		case 1:
+			b.delete(n)
+			remaining -= n
+		default:
+			b.insert(randomText(r, 1+r.Intn(3)))
+		}
+	}
+	if r.Intn(2) == 0 {
+		b.insert(randomText(r, 1+r.Intn(3)))
+	}
+	return trimRetain(b.edit)
+}
+
+func TestTransformTextConverges(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := range 2000 {
+		text := randomText(r, r.Intn(12))
+		a, b := randomEdit(r, text), randomEdit(r, text)
+
+		aPrime, bPrime, err := transformText(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		afterA, err := a.apply(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		left, err := bPrime.apply(afterA)
+		if err != nil {
+			t.Fatalf("%d: b' %v doesn't apply after a %v: %v", i, bPrime, a, err)
+		}
+
+		afterB, err := b.apply(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		right, err := aPrime.apply(afterB)
+		if err != nil {
+			t.Fatalf("%d: a' %v doesn't apply after b %v: %v", i, aPrime, b, err)
+		}
+
+		if left != right {
+			t.Fatalf("%d: %q with a %v and b %v diverges: %q != %q", i, text, a, b, left, right)
+		}
+	}
+}
+
+func TestDiffText(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+
+	for range 1000 {
+		a, b := randomText(r, r.Intn(20)), randomText(r, r.Intn(20))
+		got, err := diffText(a, b).apply(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != b {
+			t.Fatalf("diff of %q and %q gives %q", a, b, got)
+		}
+	}
+
+	// Unchanged characters are retained, so edits between them can be merged
+	edit := diffText("one two three", "one 2 three!")
+	want := TextEdit{{Retain: 4}, {Insert: "2"}, {Delete: 3}, {Retain: 6}, {Insert: "!"}}
+	if !equalEdits(edit, want) {
+		t.Fatalf("got %v, want %v", edit, want)
+	}
+}
+
+func equalEdits(a, b TextEdit) bool {
+	aj, _ := json.Marshal(a)
+	bj, _ := json.Marshal(b)
+	return string(aj) == string(bj)
+}
+
+func TestConcurrentContentEditsMerge(t *testing.T) {
+	orders := [][]int{{0, 1}, {1, 0}}
+	for _, order := range orders {
+		m := newTestManager(t)
+		g := newTestGraph(t, m, "The quick fox")
+		base := g.Version
+
+		// Both clients replace the whole content, based on the same version
+		ops := []*Operation{
+			{Type: OpUpdateNode, ClientID: "alice", BaseVersion: base, NodeID: "a", Content: ptr("The quick brown fox")},
+			{Type: OpUpdateNode, ClientID: "bob", BaseVersion: base, NodeID: "a", Content: ptr("The slow fox jumps")},
+		}
+		for _, i := range order {
+			mustApply(t, m, g.ID, ops[i])
+		}
+
+		if got, want := content(t, m, g.ID, "a"), "The slow brown fox jumps"; got != want {
+			t.Errorf("order %v: got %q, want %q", order, got, want)
+		}
+	}
+}
+
+func TestConcurrentInsertsAtSamePosition(t *testing.T) {
+	m := newTestManager(t)
+	g := newTestGraph(t, m, "ac")
+	base := g.Version
+
+	mustApply(t, m, g.ID, &Operation{Type: OpUpdateNode, ClientID: "alice", BaseVersion: base, NodeID: "a",
+		ContentEdit: TextEdit{{Retain: 1}, {Insert: "1"}}})
+	mustApply(t, m, g.ID, &Operation{Type: OpUpdateNode, ClientID: "bob", BaseVersion: base, NodeID: "a",
+		ContentEdit: TextEdit{{Retain: 1}, {Insert: "2"}}})
+
+	// The edit applied first comes first
+	if got, want := content(t, m, g.ID, "a"), "a12c"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestOwnEditsBuildOnEachOther(t *testing.T) {
+	// Alice sends two edits without seeing Carol's, the second one made on top of her first
+	var (
+		carol      = &Operation{Type: OpUpdateNode, ClientID: "carol", NodeID: "a", ContentEdit: TextEdit{{Retain: 2}, {Insert: "X"}}}
+		aliceFirst = &Operation{Type: OpUpdateNode, ClientID: "alice", NodeID: "a", ContentEdit: TextEdit{{Insert: "1"}}}
+		// Deletes "b" from "1abcd"
+		aliceSecond = &Operation{Type: OpUpdateNode, ClientID: "alice", NodeID: "a", ContentEdit: TextEdit{{Retain: 2}, {Delete: 1}}}
+	)
+
+	for _, tt := range []struct {
+		name   string
+		ops    []*Operation
+		reload bool
+	}{
+		{name: "others first", ops: []*Operation{carol, aliceFirst, aliceSecond}},
+		{name: "others between", ops: []*Operation{aliceFirst, carol, aliceSecond}},
+		{name: "others last", ops: []*Operation{aliceFirst, aliceSecond, carol}},
+		{name: "new content", ops: []*Operation{
+			carol,
+			{Type: OpUpdateNode, ClientID: "alice", NodeID: "a", Content: ptr("1abcd")},
+			{Type: OpUpdateNode, ClientID: "alice", NodeID: "a", Content: ptr("1acd")},
+		}},
+		{name: "after reload", ops: []*Operation{carol, aliceFirst, aliceSecond}, reload: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			storage, err := NewFileStorage(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := NewManager(storage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := newTestGraph(t, m, "abcd")
+			base := g.Version
+
+			for i, op := range tt.ops {
+				op = op.clone()
+				op.BaseVersion = base
+				mustApply(t, m, g.ID, op)
+
+				if tt.reload && i == len(tt.ops)-2 {
+					// Only the stored history is left to merge with
+					if m, err = NewManager(storage); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			if got, want := content(t, m, g.ID, "a"), "1aXcd"; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestInterleavedOperationsConverge(t *testing.T) {
+	m := newTestManager(t)
+	g := newTestGraph(t, m, "shared notes")
+
+	sub, err := m.Subscribe(g.ID, g.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	var replica *Graph
+	err = m.View(g.ID, func(g *Graph) error {
+		replica = g.Clone()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := replica.Version
+
+	// Three clients make changes at the same base version, which reach the server interleaved
+	ops := []*Operation{
+		{Type: OpUpdateNode, ClientID: "alice", BaseVersion: base, NodeID: "a", Position: &Position{X: 10}},
+		{Type: OpUpdateNode, ClientID: "bob", BaseVersion: base, NodeID: "a", Content: ptr("shared meeting notes")},
+		{Type: OpRemoveNode, ClientID: "carol", BaseVersion: base, NodeID: "b"},
+		{Type: OpUpdateNode, ClientID: "alice", BaseVersion: base, NodeID: "a", ContentEdit: TextEdit{{Insert: "# "}}},
+		{Type: OpUpdateNode, ClientID: "bob", BaseVersion: base, NodeID: "b", Position: &Position{Y: 5}},
+		{Type: OpUpdateEdge, ClientID: "bob", BaseVersion: base, Edge: &Edge{ID: "ab", Source: "a", Target: "b", Label: "blocks"}},
+		{Type: OpAddEdge, ClientID: "alice", BaseVersion: base, Edge: &Edge{ID: "ba", Source: "b", Target: "a"}},
+		{Type: OpUpdateNode, ClientID: "carol", BaseVersion: base, NodeID: "a", Content: ptr("shared notes!"), Position: &Position{X: 20}},
+		{Type: OpRename, ClientID: "carol", BaseVersion: base, Name: "renamed"},
+	}
+	for _, op := range ops {
+		mustApply(t, m, g.ID, op)
+	}
+
+	// Changes to the removed node and its edges are discarded
+	for _, i := range []int{4, 5, 6} {
+		if ops[i].Version != 0 {
+			t.Errorf("operation %d on a removed node was applied as version %d", i, ops[i].Version)
+		}
+	}
+
+	// A client that applies the broadcast operations in order ends up with the server's graph
+	for len(sub.Operations()) > 0 {
+		op := <-sub.Operations()
+		if err := replica.Apply(op.clone()); err != nil {
+			t.Fatalf("failed to apply broadcast version %d: %v", op.Version, err)
+		}
+		replica.Version = op.Version
+	}
+
+	err = m.View(g.ID, func(g *Graph) error {
+		if replica.Version != g.Version {
+			t.Errorf("replica is at version %d, server at %d", replica.Version, g.Version)
+		}
+		d := NewDiff(replica, g)
+		if d.Name != nil || len(d.AddedNodes)+len(d.RemovedNodes)+len(d.ChangedNodes)+len(d.AddedEdges)+len(d.RemovedEdges)+len(d.ChangedEdges) > 0 {
+			t.Errorf("replica differs from the server: %+v", d)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := content(t, m, g.ID, "a"), "# shared meeting notes!"; got != want {
+		t.Errorf("got content %q, want %q", got, want)
+	}
+	if got := replica.Node("a").Position; got.X != 20 {
+		t.Errorf("the last move should win, got %v", got)
+	}
+}
+
+func TestOfflineEditsReplayed(t *testing.T) {
+	m := newTestManager(t)
+	g := newTestGraph(t, m, "one\ntwo\nthree")
+	base := g.Version
+
+	// Someone else keeps editing while the client is offline, more than the in-memory log holds
+	for i := range operationLogSize + 10 {
+		mustApply(t, m, g.ID, &Operation{Type: OpUpdateNode, ClientID: "online", BaseVersion: g.Version, NodeID: "b",
+			Position: &Position{X: float64(i)}})
+	}
+	mustApply(t, m, g.ID, &Operation{Type: OpUpdateNode, ClientID: "online", NodeID: "a", Content: ptr("zero\none\ntwo\nthree")})
+
+	// The offline client's operations are sent in order, each based on the version it last saw
+	offline := []*Operation{
+		{Type: OpUpdateNode, ClientID: "offline", BaseVersion: base, NodeID: "a", Content: ptr("one\ntwo\nthree\nfour")},
+		{Type: OpAddNode, ClientID: "offline", BaseVersion: base, Node: &Node{ID: "c", Type: NodeTypeText, Content: "new"}},
+		{Type: OpAddEdge, ClientID: "offline", BaseVersion: base, Edge: &Edge{ID: "ac", Source: "a", Target: "c"}},
+	}
+	for _, op := range offline {
+		mustApply(t, m, g.ID, op)
+	}
+
+	if got, want := content(t, m, g.ID, "a"), "zero\none\ntwo\nthree\nfour"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Replaying the same operations again, e.g. after a lost response, changes nothing
+	version := g.Version
+	for _, op := range offline[1:] {
+		replayed := op.clone()
+		replayed.Version = 0
+		mustApply(t, m, g.ID, replayed)
+		if replayed.Version != 0 {
+			t.Errorf("replayed %s was applied again", op.Type)
+		}
+	}
+	if g.Version != version {
+		t.Errorf("replaying changed the version from %d to %d", version, g.Version)
+	}
+}
+
+func TestRebaseErrors(t *testing.T) {
+	m := newTestManager(t)
+	g := newTestGraph(t, m, "text")
+
+	err := m.Apply(g.ID, &Operation{Type: OpRename, BaseVersion: g.Version + 1, Name: "x"})
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("a base version from the future should be invalid, got %v", err)
+	}
+
+	// Without a base version, operations on missing nodes are errors as before
+	err = m.Apply(g.ID, &Operation{Type: OpRemoveNode, NodeID: "missing"})
+	if !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("got %v, want ErrNodeNotFound", err)
+	}
+
+	// An edit can't be merged with content that was replaced before edits were recorded
+	base := g.Version
+	mustApply(t, m, g.ID, &Operation{Type: OpRemoveNode, NodeID: "a"})
+	mustApply(t, m, g.ID, &Operation{Type: OpAddNode, Node: &Node{ID: "a", Type: NodeTypeText, Content: "other"}})
+	err = m.Apply(g.ID, &Operation{Type: OpUpdateNode, BaseVersion: base, NodeID: "a", ContentEdit: TextEdit{{Retain: 4}, {Insert: "!"}}})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("got %v, want ErrConflict", err)
+	}
+}
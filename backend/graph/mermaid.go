@@ -0,0 +1,322 @@
+package graph
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mermaidNode is a node of a parsed Mermaid flowchart
+type mermaidNode struct {
+	id    string
+	label string
+}
+
+// mermaidEdge is an edge of a parsed Mermaid flowchart
+type mermaidEdge struct {
+	source, target string
+	label          string
+	directed       bool
+}
+
+type mermaidChart struct {
+	title     string
+	direction LayoutDirection
+	nodes     []*mermaidNode
+	edges     []mermaidEdge
+
+	byID map[string]*mermaidNode
+}
+
+// node returns the node with the given ID, adding it if it doesn't exist yet. Nodes are labeled with their ID by default
+func (c *mermaidChart) node(id string) *mermaidNode {
+	if n, ok := c.byID[id]; ok {
+		return n
+	}
+	n := &mermaidNode{id: id, label: id}
+	c.byID[id] = n
+	c.nodes = append(c.nodes, n)
+	return n
+}
+
+var (
+	mermaidHeader = regexp.MustCompile(`^(?:flowchart|graph)(?:\s+(TB|TD|BT|LR|RL))?\s*;?$`)
+	mermaidNodeID = regexp.MustCompile(`^[\p{L}\p{N}_]+(?:[-.][\p{L}\p{N}_]+)*`)
+	// mermaidTextLink matches links with the label in the middle, like A -- text --> B
+	mermaidTextLink = regexp.MustCompile(`^\s*[xo<]?(?:--|==|-\.)\s+(.+?)\s+(-{2,}[>xo]?|={2,}[>xo]?|\.+-[>xo]?)\s*`)
+	// mermaidLink matches links like -->, ---, -.->, ==> and ~~~, with an optional |label|
+	mermaidLink      = regexp.MustCompile(`^\s*[xo<]?(-{2,}[>xo]?|={2,}[>xo]?|-\.+-[>xo]?|~{3,})\s*(?:\|([^|]*)\|\s*)?`)
+	mermaidEntity    = regexp.MustCompile(`#(\w+);`)
+	mermaidLineBreak = regexp.MustCompile(`(?i)<br\s*/?>`)
+	mermaidClass     = regexp.MustCompile(`^:::[\w-]+`)
+	mermaidShapeData = regexp.MustCompile(`^@\{([^}]*)\}`)
+	mermaidDataLabel = regexp.MustCompile(`label:\s*"((?:[^"\\]|\\.)*)"`)
+)
+
+// mermaidShapes maps the openers of node shapes to their closers. Longer openers come first, as they start with shorter ones
+var mermaidShapes = []struct{ open, close string }{
+	{"(((", ")))"},
+	{"((", "))"},
+	{"([", "])"},
+	{"[[", "]]"},
+	{"[(", ")]"},
+	{"{{", "}}"},
+	{"[/", "/]"},
+	{"[/", `\]`},
+	{`[\`, `\]`},
+	{`[\`, "/]"},
+	{"(", ")"},
+	{"[", "]"},
+	{"{", "}"},
+	{">", "]"},
+}
+
+var mermaidEntities = map[string]string{
+	"quot": `"`,
+	"amp":  "&",
+	"lt":   "<",
+	"gt":   ">",
+	"nbsp": " ",
+}
+
+// unescapeMermaid decodes entity codes like #quot; or #35; and line breaks in labels
+func unescapeMermaid(s string) string {
+	s = mermaidLineBreak.ReplaceAllString(s, "\n")
+	return mermaidEntity.ReplaceAllStringFunc(s, func(m string) string {
+		name := m[1 : len(m)-1]
+		if v, ok := mermaidEntities[name]; ok {
+			return v
+		}
+		if code, err := strconv.Atoi(name); err == nil {
+			return string(rune(code))
+		}
+		return m
+	})
+}
+
+// cleanMermaidLabel removes the quotes and markdown string backticks around a label and decodes it
+func cleanMermaidLabel(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	if len(s) >= 2 && s[0] == '`' && s[len(s)-1] == '`' {
+		s = s[1 : len(s)-1]
+	}
+	return strings.TrimSpace(unescapeMermaid(s))
+}
+
+// mermaidStatements splits the source into statements, skipping front matter and comments
+func mermaidStatements(src string) []string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	var statements []string
+	var inFrontMatter bool
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "---" && (i == 0 || inFrontMatter) {
+			inFrontMatter = !inFrontMatter
+			statements = append(statements, line)
+			continue
+		}
+		if inFrontMatter {
+			statements = append(statements, line)
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "%%") {
+			continue
+		}
+
+		// Semicolons separate statements, unless they are part of a quoted label
+		var quoted bool
+		var start int
+		for j, r := range line {
+			switch {
+			case r == '"':
+				quoted = !quoted
+			case r == ';' && !quoted:
+				if s := strings.TrimSpace(line[start:j]); s != "" {
+					statements = append(statements, s)
+				}
+				start = j + 1
+			}
+		}
+		if s := strings.TrimSpace(line[start:]); s != "" {
+			statements = append(statements, s)
+		}
+	}
+
+	return statements
+}
+
+// parseMermaid parses the nodes and edges of a Mermaid flowchart. Subgraphs are flattened and styling is ignored
+func parseMermaid(src string) (*mermaidChart, error) {
+	chart := &mermaidChart{
+		direction: LayoutTopBottom,
+		byID:      make(map[string]*mermaidNode),
+	}
+
+	var headerSeen, inFrontMatter bool
+	for lineNo, stmt := range mermaidStatements(src) {
+		if stmt == "---" {
+			inFrontMatter = !inFrontMatter
+			continue
+		}
+		if inFrontMatter {
+			if title, ok := strings.CutPrefix(stmt, "title:"); ok {
+				title = strings.TrimSpace(title)
+				if unquoted, err := strconv.Unquote(title); err == nil {
+					title = unquoted
+				}
+				chart.title = title
+			}
+			continue
+		}
+
+		if !headerSeen {
+			m := mermaidHeader.FindStringSubmatch(stmt)
+			if m == nil {
+				return nil, fmt.Errorf("%w: not a Mermaid flowchart, expected \"flowchart\" or \"graph\" but got %q", ErrInvalid, stmt)
+			}
+			switch m[1] {
+			case "BT":
+				chart.direction = LayoutBottomTop
+			case "LR":
+				chart.direction = LayoutLeftRight
+			case "RL":
+				chart.direction = LayoutRightLeft
+			}
+			headerSeen = true
+			continue
+		}
+
+		keyword, _, _ := strings.Cut(stmt, " ")
+		switch keyword {
+		case "subgraph", "end", "click", "classDef", "class", "style", "linkStyle", "direction", "accTitle:", "accDescr:":
+			continue
+		}
+
+		if err := chart.parseChain(stmt); err != nil {
+			return nil, fmt.Errorf("%w: statement %d %q: %v", ErrInvalid, lineNo+1, stmt, err)
+		}
+	}
+
+	if !headerSeen {
+		return nil, fmt.Errorf("%w: empty Mermaid flowchart", ErrInvalid)
+	}
+
+	return chart, nil
+}
+
+// parseChain parses a statement like A[Label] & B --> C -->|text| D
+func (c *mermaidChart) parseChain(stmt string) error {
+	rest := stmt
+
+	previous, rest, err := c.parseNodeGroup(rest)
+	if err != nil {
+		return err
+	}
+
+	for strings.TrimSpace(rest) != "" {
+		var arrow, label string
+		if m := mermaidTextLink.FindStringSubmatch(rest); m != nil {
+			label, arrow = m[1], m[2]
+			rest = rest[len(m[0]):]
+		} else if m := mermaidLink.FindStringSubmatch(rest); m != nil {
+			arrow, label = m[1], m[2]
+			rest = rest[len(m[0]):]
+		} else {
+			return fmt.Errorf("expected a link at %q", strings.TrimSpace(rest))
+		}
+
+		var next []string
+		next, rest, err = c.parseNodeGroup(rest)
+		if err != nil {
+			return err
+		}
+
+		directed := strings.ContainsAny(arrow[len(arrow)-1:], ">xo")
+		for _, source := range previous {
+			for _, target := range next {
+				c.edges = append(c.edges, mermaidEdge{
+					source:   source,
+					target:   target,
+					label:    cleanMermaidLabel(label),
+					directed: directed,
+				})
+			}
+		}
+		previous = next
+	}
+
+	return nil
+}
+
+// parseNodeGroup parses one or more nodes joined by &
+func (c *mermaidChart) parseNodeGroup(s string) (ids []string, rest string, err error) {
+	rest = s
+	for {
+		var id string
+		id, rest, err = c.parseNode(rest)
+		if err != nil {
+			return nil, "", err
+		}
+		ids = append(ids, id)
+
+		trimmed := strings.TrimSpace(rest)
+		if !strings.HasPrefix(trimmed, "&") {
+			return ids, rest, nil
+		}
+		rest = trimmed[1:]
+	}
+}
+
+// parseNode parses a node ID with an optional shape and label
+func (c *mermaidChart) parseNode(s string) (id string, rest string, err error) {
+	s = strings.TrimSpace(s)
+	id = mermaidNodeID.FindString(s)
+	if id == "" {
+		return "", "", fmt.Errorf("expected a node at %q", s)
+	}
+	rest = s[len(id):]
+	node := c.node(id)
+
+	// Shape data like A@{ shape: rect, label: "Text" }
+	if m := mermaidShapeData.FindStringSubmatch(rest); m != nil {
+		if lm := mermaidDataLabel.FindStringSubmatch(m[1]); lm != nil {
+			node.label = cleanMermaidLabel(`"` + lm[1] + `"`)
+		}
+		rest = rest[len(m[0]):]
+	}
+
+	for _, shape := range mermaidShapes {
+		if !strings.HasPrefix(rest, shape.open) {
+			continue
+		}
+
+		inner := rest[len(shape.open):]
+		var end int
+		if strings.HasPrefix(inner, `"`) {
+			// Quoted labels can contain the closer
+			closing := strings.Index(inner[1:], `"`)
+			if closing < 0 {
+				return "", "", fmt.Errorf("unterminated label of node %q", id)
+			}
+			end = closing + 2
+		}
+		closer := strings.Index(inner[end:], shape.close)
+		if closer < 0 {
+			continue
+		}
+		end += closer
+
+		node.label = cleanMermaidLabel(inner[:end])
+		rest = inner[end+len(shape.close):]
+		break
+	}
+
+	rest = mermaidClass.ReplaceAllString(rest, "")
+
+	return id, rest, nil
+}
@@ -0,0 +1,152 @@
+package graph
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseMermaid(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		src       string
+		title     string
+		direction LayoutDirection
+		// nodes maps the IDs to the labels
+		nodes map[string]string
+		edges []mermaidEdge
+	}{
+		{
+			name: "shapes",
+			src: `flowchart LR
+				A[Rect] --> B(Round)
+				C([Stadium]) --> D[[Subroutine]]
+				E[(Database)] --> F((Circle))
+				G{Rhombus} --> H{{Hexagon}}
+				I[/Parallelogram/] --> J[\Alt\]
+				K[/Trapezoid\] --> L>Flag]
+				M(((Double)))
+				N@{ shape: rect, label: "Data" }`,
+			direction: LayoutLeftRight,
+			nodes: map[string]string{
+				"A": "Rect", "B": "Round", "C": "Stadium", "D": "Subroutine", "E": "Database", "F": "Circle",
+				"G": "Rhombus", "H": "Hexagon", "I": "Parallelogram", "J": "Alt", "K": "Trapezoid", "L": "Flag",
+				"M": "Double", "N": "Data",
+			},
+			edges: []mermaidEdge{
+				{source: "A", target: "B", directed: true},
+				{source: "C", target: "D", directed: true},
+				{source: "E", target: "F", directed: true},
+				{source: "G", target: "H", directed: true},
+				{source: "I", target: "J", directed: true},
+				{source: "K", target: "L", directed: true},
+			},
+		},
+		{
+			name: "link labels",
+			src: `graph TD
+				A -- label text --> B
+				C == thick ==> D
+				E -. dotted .-> F
+				G -->|pipe| H --- I
+				J ~~~ K`,
+			direction: LayoutTopBottom,
+			nodes: map[string]string{
+				"A": "A", "B": "B", "C": "C", "D": "D", "E": "E", "F": "F", "G": "G", "H": "H", "I": "I", "J": "J", "K": "K",
+			},
+			edges: []mermaidEdge{
+				{source: "A", target: "B", label: "label text", directed: true},
+				{source: "C", target: "D", label: "thick", directed: true},
+				{source: "E", target: "F", label: "dotted", directed: true},
+				{source: "G", target: "H", label: "pipe", directed: true},
+				{source: "H", target: "I"},
+				{source: "J", target: "K"},
+			},
+		},
+		{
+			name:      "node groups",
+			src:       "flowchart TB\nA & B --> C & D",
+			direction: LayoutTopBottom,
+			nodes:     map[string]string{"A": "A", "B": "B", "C": "C", "D": "D"},
+			edges: []mermaidEdge{
+				{source: "A", target: "C", directed: true},
+				{source: "A", target: "D", directed: true},
+				{source: "B", target: "C", directed: true},
+				{source: "B", target: "D", directed: true},
+			},
+		},
+		{
+			name: "quoted labels",
+			src: `flowchart TD
+				A["Label with ] bracket"] --> B("a (b) c")
+				C{"x } y"}; D["semi;colon"]`,
+			direction: LayoutTopBottom,
+			nodes:     map[string]string{"A": "Label with ] bracket", "B": "a (b) c", "C": "x } y", "D": "semi;colon"},
+			edges:     []mermaidEdge{{source: "A", target: "B", directed: true}},
+		},
+		{
+			name:      "entities and line breaks",
+			src:       "flowchart TD\nA[\"Say #quot;hi#quot; #35;1<br/>second #amp; #lt;line#gt;\"]",
+			direction: LayoutTopBottom,
+			nodes:     map[string]string{"A": "Say \"hi\" #1\nsecond & <line>"},
+		},
+		{
+			name: "front matter, comments and styling",
+			src: `---
+title: "My chart"
+---
+flowchart RL
+	%% a comment
+	subgraph one
+		A:::important --> B
+	end
+	classDef important fill:#f00
+	style A stroke:#333
+	click A "https://example.com"`,
+			title:     "My chart",
+			direction: LayoutRightLeft,
+			nodes:     map[string]string{"A": "A", "B": "B"},
+			edges:     []mermaidEdge{{source: "A", target: "B", directed: true}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			chart, err := parseMermaid(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if chart.title != tt.title {
+				t.Errorf("got title %q, want %q", chart.title, tt.title)
+			}
+			if chart.direction != tt.direction {
+				t.Errorf("got direction %q, want %q", chart.direction, tt.direction)
+			}
+
+			var nodes = make(map[string]string, len(chart.nodes))
+			for _, n := range chart.nodes {
+				nodes[n.id] = n.label
+			}
+			if !reflect.DeepEqual(nodes, tt.nodes) {
+				t.Errorf("got nodes %q, want %q", nodes, tt.nodes)
+			}
+			if !reflect.DeepEqual(chart.edges, tt.edges) {
+				t.Errorf("got edges %+v, want %+v", chart.edges, tt.edges)
+			}
+		})
+	}
+}
+
+func TestParseMermaidErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"%% only a comment",
+		"pie title Pets",
+		"flowchart TD\nA -->",
+		"flowchart TD\nA ==",
+		"flowchart TD\nA[\"unterminated] --> B",
+	} {
+		if _, err := parseMermaid(src); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%q: got %v, want ErrInvalid", src, err)
+		}
+	}
+}
@@ -1,10 +1,31 @@
 package graph
 
-import "sync"
+import (
+	"pathflux/meili"
+	"sync"
+	"time"
+)
 
 type Graph struct {
 	lock sync.RWMutex
 
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Version is incremented by every applied operation
+	Version uint64 `json:"version"`
+
+	// log holds the most recent operations, so reconnecting clients can catch up
+	log         []*Operation
+	subscribers map[*Subscription]struct{}
+	// undo holds the changes each user can undo and redo
+	undo map[int]*undoStacks
+	// sinceCheckpoint counts the operations logged after the last base record, see Manager.checkpoint
+	sinceCheckpoint int
+
 	Nodes []*Node `json:"nodes"`
 	Edges []*Edge `json:"edges"`
 }
@@ -19,6 +40,14 @@ type Node struct {
 	Type NodeType `json:"type"`
 
 	Position Position `json:"position"`
+
+	// Content is the markdown string of a NodeTypeText node
+	Content string `json:"content,omitempty"`
+
+	// ItemID is the ID of the meili.GitLabItem a NodeTypeGitLabItem node references, e.g. "i123", "mr45" or "e7"
+	ItemID string `json:"item_id,omitempty"`
+	// Item is the current state of the referenced item. It is filled in when the graph is sent to clients and never stored
+	Item *meili.GitLabItem `json:"item,omitempty"`
 }
 
 type Marker struct {
@@ -35,6 +64,9 @@ type Edge struct {
 	Source string `json:"source"`
 	Target string `json:"target"`
 
+	// Label is shown on the edge. Generated edges use the meili.RelationType they were derived from
+	Label string `json:"label,omitempty"`
+
 	MarkerEnd string `json:"markerEnd"`
 }
 
@@ -43,12 +75,56 @@ type NodeType string
 const (
 	// NodeTypeText is a markdown text node
 	NodeTypeText NodeType = "text"
+	// NodeTypeGitLabItem shows an issue, merge request or epic from the index
+	NodeTypeGitLabItem NodeType = "gitlab_item"
 )
 
-// Literally just a markdown text node
-type TextNode struct {
-	Node
+func (t NodeType) Valid() bool {
+	switch t {
+	case NodeTypeText, NodeTypeGitLabItem:
+		return true
+	default:
+		return false
+	}
+}
+
+// Clone returns a deep copy of the graph's contents. Callers must hold at least a read lock
+func (g *Graph) Clone() *Graph {
+	c := &Graph{
+		ID:        g.ID,
+		Name:      g.Name,
+		CreatedAt: g.CreatedAt,
+		UpdatedAt: g.UpdatedAt,
+		Version:   g.Version,
+		Nodes:     make([]*Node, 0, len(g.Nodes)),
+		Edges:     make([]*Edge, 0, len(g.Edges)),
+	}
+
+	for _, n := range g.Nodes {
+		node := *n
+		c.Nodes = append(c.Nodes, &node)
+	}
+	for _, e := range g.Edges {
+		edge := *e
+		c.Edges = append(c.Edges, &edge)
+	}
+
+	return c
+}
 
-	// Content is a markdown string
-	Content string `json:"content"`
+// ItemIDs returns the distinct item IDs referenced by NodeTypeGitLabItem nodes. Callers must hold at least a read lock
+func (g *Graph) ItemIDs() []string {
+	var seen = make(map[string]struct{})
+	var ids []string
+	for _, n := range g.Nodes {
+		if n.Type != NodeTypeGitLabItem {
+			continue
+		}
+		if _, ok := seen[n.ItemID]; ok {
+			continue
+		}
+		seen[n.ItemID] = struct{}{}
+		ids = append(ids, n.ItemID)
+	}
+	return ids
 }
@@ -0,0 +1,134 @@
+package graph
+
+import "fmt"
+
+type OperationType string
+
+const (
+	OpAddNode    OperationType = "add_node"
+	OpUpdateNode OperationType = "update_node"
+	OpRemoveNode OperationType = "remove_node"
+	OpAddEdge    OperationType = "add_edge"
+	OpUpdateEdge OperationType = "update_edge"
+	OpRemoveEdge OperationType = "remove_edge"
+	OpRename     OperationType = "rename"
+)
+
+// Operation is a single change to a graph. Operations are applied in order and broadcast to everyone viewing the graph
+type Operation struct {
+	Type OperationType `json:"type"`
+
+	// Version is the graph version this operation produced. It is set when the operation is applied,
+	// and stays 0 if the operation was discarded because a concurrent change removed what it refers to
+	Version uint64 `json:"version"`
+	// BaseVersion is the graph version the client had when it made the operation. If other clients changed the graph since,
+	// the operation is merged with their changes, see Manager.Apply. If it is 0, the operation is applied to the current graph as is
+	BaseVersion uint64 `json:"base_version,omitempty"`
+
+	// ClientID identifies the client that sent the operation, so it can recognize its own changes
+	ClientID string `json:"client_id,omitempty"`
+	// UserID is the GitLab user who made the change. It is recorded in the graph's history
+	UserID int `json:"user_id,omitempty"`
+
+	// Node is set for add_node
+	Node *Node `json:"node,omitempty"`
+	// Edge is set for add_edge and update_edge
+	Edge *Edge `json:"edge,omitempty"`
+
+	// NodeID is set for update_node and remove_node
+	NodeID string `json:"node_id,omitempty"`
+	// EdgeID is set for remove_edge
+	EdgeID string `json:"edge_id,omitempty"`
+
+	// Position and Content are the optional changes of update_node
+	Position *Position `json:"position,omitempty"`
+	Content  *string   `json:"content,omitempty"`
+	// ContentEdit changes parts of the content of update_node instead of replacing it, so concurrent edits can be merged.
+	// Applied operations have both, Content is then the resulting content and ContentEdit the change from the previous one
+	ContentEdit TextEdit `json:"content_edit,omitempty"`
+	// clientEdit is the content edit as the sender made it, if it had to be transformed against concurrent edits.
+	// Later operations of the sender are based on it
+	clientEdit TextEdit
+
+	// Name is set for rename
+	Name string `json:"name,omitempty"`
+}
+
+// Apply performs the operation on the graph. Callers must hold the write lock.
+// If an error is returned, the graph is unchanged
+func (g *Graph) Apply(op *Operation) error {
+	switch op.Type {
+	case OpAddNode:
+		if op.Node == nil {
+			return fmt.Errorf("%w: %s requires a node", ErrInvalid, op.Type)
+		}
+		// The graph gets its own copy, so the operation keeps describing the node as it was added
+		node := *op.Node
+		if err := g.AddNode(&node); err != nil {
+			return err
+		}
+		*op.Node = node
+		return nil
+	case OpUpdateNode:
+		n := g.Node(op.NodeID)
+		if n == nil {
+			return ErrNodeNotFound
+		}
+		// Content is set first because it is the only change that can be rejected for an existing node
+		if op.ContentEdit != nil || op.Content != nil {
+			if n.Type != NodeTypeText {
+				return fmt.Errorf("%w: node %q of type %q has no content", ErrInvalid, n.ID, n.Type)
+			}
+
+			// Both are recorded, so later edits can be merged with this one and clients can simply take the new content
+			content, edit := op.Content, op.ContentEdit
+			if edit != nil {
+				c, err := edit.apply(n.Content)
+				if err != nil {
+					return err
+				}
+				content = &c
+			} else {
+				edit = diffText(n.Content, *content)
+			}
+			if err := g.SetNodeContent(n.ID, *content); err != nil {
+				return err
+			}
+			op.Content, op.ContentEdit = content, edit
+		}
+		if op.Position != nil {
+			if err := g.MoveNode(op.NodeID, *op.Position); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OpRemoveNode:
+		return g.RemoveNode(op.NodeID)
+	case OpAddEdge:
+		if op.Edge == nil {
+			return fmt.Errorf("%w: %s requires an edge", ErrInvalid, op.Type)
+		}
+		edge := *op.Edge
+		if err := g.AddEdge(&edge); err != nil {
+			return err
+		}
+		*op.Edge = edge
+		return nil
+	case OpUpdateEdge:
+		if op.Edge == nil {
+			return fmt.Errorf("%w: %s requires an edge", ErrInvalid, op.Type)
+		}
+		edge := *op.Edge
+		return g.ReplaceEdge(&edge)
+	case OpRemoveEdge:
+		return g.RemoveEdge(op.EdgeID)
+	case OpRename:
+		if op.Name == "" {
+			return fmt.Errorf("%w: graph name must not be empty", ErrInvalid)
+		}
+		g.Name = op.Name
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown operation type %q", ErrInvalid, op.Type)
+	}
+}
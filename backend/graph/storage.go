@@ -0,0 +1,275 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage persists graphs so they survive restarts
+type Storage interface {
+	// LoadAll returns every stored graph
+	LoadAll() ([]*Graph, error)
+	// Save writes the graph. The caller holds at least a read lock on it
+	Save(g *Graph) error
+	// Delete removes the graph with the given ID together with its history and snapshots.
+	// Deleting a graph that does not exist is not an error
+	Delete(id string) error
+
+	// AppendHistory adds records to the end of the graph's change log
+	AppendHistory(id string, records ...HistoryRecord) error
+	// LoadHistory returns the graph's change log, oldest record first. It starts at the newest base record
+	// at or before the since version, as the records before it are only needed for older versions
+	LoadHistory(id string, since uint64) ([]HistoryRecord, error)
+	// CompactHistory drops the records that are only needed for versions before the given one, see LoadHistory
+	CompactHistory(id string, before uint64) error
+
+	// SaveSnapshots replaces the named snapshots of the graph
+	SaveSnapshots(id string, snapshots []*Snapshot) error
+	// LoadSnapshots returns the named snapshots of the graph
+	LoadSnapshots(id string) ([]*Snapshot, error)
+}
+
+// HistoryRecord is an entry of a graph's change log. It holds either the Operation that was applied at Time,
+// or a Base: the full graph at its version, which later operations build upon
+type HistoryRecord struct {
+	Time time.Time `json:"time"`
+
+	Operation *Operation `json:"operation,omitempty"`
+	Base      *Graph     `json:"base,omitempty"`
+
+	// ClientEdit is the content edit of the Operation as its sender made it, see Manager.Apply
+	ClientEdit TextEdit `json:"client_edit,omitempty"`
+}
+
+// FileStorage stores each graph as a JSON file in a directory.
+// Change logs are stored as JSON lines in the history subdirectory, snapshots in the snapshots subdirectory
+type FileStorage struct {
+	dir string
+}
+
+const (
+	graphFileExtension   = ".json"
+	historyFileExtension = ".jsonl"
+
+	historyDir   = "history"
+	snapshotsDir = "snapshots"
+)
+
+func NewFileStorage(dir string) (*FileStorage, error) {
+	for _, d := range []string{dir, filepath.Join(dir, historyDir), filepath.Join(dir, snapshotsDir)} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create graph directory: %w", err)
+		}
+	}
+
+	return &FileStorage{
+		dir: dir,
+	}, nil
+}
+
+func (s *FileStorage) path(id string) string {
+	return filepath.Join(s.dir, id+graphFileExtension)
+}
+
+func (s *FileStorage) LoadAll() (graphs []*Graph, err error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list graph directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), graphFileExtension) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read graph file %q: %w", entry.Name(), err)
+		}
+
+		var g Graph
+		if err := json.Unmarshal(data, &g); err != nil {
+			return nil, fmt.Errorf("failed to parse graph file %q: %w", entry.Name(), err)
+		}
+
+		graphs = append(graphs, &g)
+	}
+
+	return graphs, nil
+}
+
+func (s *FileStorage) historyPath(id string) string {
+	return filepath.Join(s.dir, historyDir, id+historyFileExtension)
+}
+
+func (s *FileStorage) snapshotsPath(id string) string {
+	return filepath.Join(s.dir, snapshotsDir, id+graphFileExtension)
+}
+
+func (s *FileStorage) Save(g *Graph) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return fmt.Errorf("failed to encode graph: %w", err)
+	}
+
+	return writeFileAtomic(s.path(g.ID), data)
+}
+
+// writeFileAtomic writes to a temporary file first and renames it, so a crash never leaves a half-written file behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileStorage) Delete(id string) error {
+	for _, path := range []string{s.path(id), s.historyPath(id), s.snapshotsPath(id)} {
+		err := os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete graph file: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *FileStorage) AppendHistory(id string, records ...HistoryRecord) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("failed to encode history record: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(s.historyPath(id), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	return f.Close()
+}
+
+// historyProbe decodes only the version of base records, which is enough to find where to start reading
+type historyProbe struct {
+	Base *struct {
+		Version uint64 `json:"version"`
+	} `json:"base"`
+}
+
+// readHistory returns the change log and the offset of the newest base record at or before the since version
+func (s *FileStorage) readHistory(id string, since uint64) (data []byte, start int, err error) {
+	data, err = os.ReadFile(s.historyPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	for offset := 0; offset < len(data); {
+		line := data[offset:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i+1]
+		}
+
+		// Strings are escaped, so only keys of base records contain this
+		if bytes.Contains(line, []byte(`"base":`)) {
+			var probe historyProbe
+			if json.Unmarshal(line, &probe) == nil && probe.Base != nil && probe.Base.Version <= since {
+				start = offset
+			}
+		}
+
+		offset += len(line)
+	}
+
+	return data, start, nil
+}
+
+func (s *FileStorage) LoadHistory(id string, since uint64) (records []HistoryRecord, err error) {
+	data, start, err := s.readHistory(id, since)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range bytes.Split(data[start:], []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var r HistoryRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			// Only the last line can be incomplete, if writing it was interrupted
+			continue
+		}
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+func (s *FileStorage) CompactHistory(id string, before uint64) error {
+	data, start, err := s.readHistory(id, before)
+	if err != nil || start == 0 {
+		return err
+	}
+
+	return writeFileAtomic(s.historyPath(id), data[start:])
+}
+
+func (s *FileStorage) SaveSnapshots(id string, snapshots []*Snapshot) error {
+	data, err := json.Marshal(snapshots)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshots: %w", err)
+	}
+
+	return writeFileAtomic(s.snapshotsPath(id), data)
+}
+
+func (s *FileStorage) LoadSnapshots(id string) (snapshots []*Snapshot, err error) {
+	data, err := os.ReadFile(s.snapshotsPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshots file: %w", err)
+	}
+
+	return snapshots, nil
+}
@@ -0,0 +1,111 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// operationLogSize is how many operations per graph are kept for catching up
+	operationLogSize = 1000
+	// subscriptionBuffer is how many operations a subscriber may lag behind before it is dropped
+	subscriptionBuffer = 64
+)
+
+// Subscription receives all operations applied to a graph
+type Subscription struct {
+	graph *Graph
+	ops   chan *Operation
+
+	// Version is the graph version at the time of subscribing
+	Version uint64
+	// Missed holds the operations applied after the requested version. It is only used if Snapshot is nil
+	Missed []*Operation
+	// Snapshot is the current graph. It is set if the requested version is too old to catch up from the operation log
+	Snapshot json.RawMessage
+}
+
+// Operations returns the channel of new operations. It is closed if the subscriber falls too far behind
+// or the graph is deleted; the client should then subscribe again with the last version it has seen
+func (s *Subscription) Operations() <-chan *Operation {
+	return s.ops
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.graph.lock.Lock()
+	defer s.graph.lock.Unlock()
+
+	if _, ok := s.graph.subscribers[s]; ok {
+		delete(s.graph.subscribers, s)
+		close(s.ops)
+	}
+}
+
+// Subscribe starts receiving operations for a graph. since is the last version the client has seen;
+// operations after it are returned in Missed, or a full Snapshot is returned if they are no longer available
+func (m *Manager) Subscribe(id string, since uint64) (*Subscription, error) {
+	g, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	sub := &Subscription{
+		graph: g,
+		ops:   make(chan *Operation, subscriptionBuffer),
+
+		Version: g.Version,
+	}
+
+	switch {
+	case since == g.Version:
+		// Up to date
+	case since > 0 && since < g.Version && len(g.log) > 0 && g.log[0].Version <= since+1:
+		for _, op := range g.log {
+			if op.Version > since {
+				sub.Missed = append(sub.Missed, op)
+			}
+		}
+	default:
+		sub.Snapshot, err = json.Marshal(g)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode graph snapshot: %w", err)
+		}
+	}
+
+	if g.subscribers == nil {
+		g.subscribers = make(map[*Subscription]struct{})
+	}
+	g.subscribers[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// publish records the operation and sends it to all subscribers. Callers must hold the write lock
+func (g *Graph) publish(op *Operation) {
+	g.log = append(g.log, op)
+	if len(g.log) > operationLogSize {
+		g.log = append([]*Operation(nil), g.log[len(g.log)-operationLogSize:]...)
+	}
+
+	for sub := range g.subscribers {
+		select {
+		case sub.ops <- op:
+		default:
+			// The subscriber is too slow, it has to reconnect and catch up from the log
+			delete(g.subscribers, sub)
+			close(sub.ops)
+		}
+	}
+}
+
+// closeSubscribers ends all subscriptions. Callers must hold the write lock
+func (g *Graph) closeSubscribers() {
+	for sub := range g.subscribers {
+		delete(g.subscribers, sub)
+		close(sub.ops)
+	}
+}
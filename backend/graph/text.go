@@ -0,0 +1,289 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TextOp is a component of a TextEdit. Exactly one of its fields is set. Lengths are counted in Unicode code points
+type TextOp struct {
+	// Retain keeps the next characters
+	Retain int `json:"retain,omitempty"`
+	// Insert adds text at the current position
+	Insert string `json:"insert,omitempty"`
+	// Delete removes the next characters
+	Delete int `json:"delete,omitempty"`
+}
+
+// TextEdit changes parts of a text, so edits of different parts made at the same time can be merged.
+// Characters after the last component are kept
+type TextEdit []TextOp
+
+// maxDiffCells bounds the work of diffText. Larger changes are described as replacing the changed range
+const maxDiffCells = 1 << 20
+
+func (e TextEdit) validate() error {
+	for _, op := range e {
+		var set int
+		if op.Retain != 0 {
+			set++
+		}
+		if op.Insert != "" {
+			set++
+		}
+		if op.Delete != 0 {
+			set++
+		}
+		if set != 1 || op.Retain < 0 || op.Delete < 0 {
+			return fmt.Errorf("%w: every text edit component must have exactly one of retain, insert or delete", ErrInvalid)
+		}
+	}
+	return nil
+}
+
+// baseLength is the number of characters the edit retains or deletes
+func (e TextEdit) baseLength() int {
+	var n int
+	for _, op := range e {
+		n += op.Retain + op.Delete
+	}
+	return n
+}
+
+// apply performs the edit on the text
+func (e TextEdit) apply(s string) (string, error) {
+	if err := e.validate(); err != nil {
+		return "", err
+	}
+
+	text := []rune(s)
+	if n := e.baseLength(); n > len(text) {
+		return "", fmt.Errorf("%w: text edit spans %d characters, but the content has %d", ErrInvalid, n, len(text))
+	}
+
+	var b strings.Builder
+	var pos int
+	for _, op := range e {
+		switch {
+		case op.Retain > 0:
+			b.WriteString(string(text[pos : pos+op.Retain]))
+			pos += op.Retain
+		case op.Delete > 0:
+			pos += op.Delete
+		default:
+			b.WriteString(op.Insert)
+		}
+	}
+	b.WriteString(string(text[pos:]))
+
+	return b.String(), nil
+}
+
+// editBuilder appends components to an edit, merging neighbours of the same kind.
+// Inserts are placed before deletes at the same position, so equal edits have the same components
+type editBuilder struct {
+	edit TextEdit
+}
+
+func (b *editBuilder) last() *TextOp {
+	if len(b.edit) == 0 {
+		return nil
+	}
+	return &b.edit[len(b.edit)-1]
+}
+
+func (b *editBuilder) retain(n int) {
+	if n <= 0 {
+		return
+	}
+	if last := b.last(); last != nil && last.Retain > 0 {
+		last.Retain += n
+		return
+	}
+	b.edit = append(b.edit, TextOp{Retain: n})
+}
+
+func (b *editBuilder) delete(n int) {
+	if n <= 0 {
+		return
+	}
+	if last := b.last(); last != nil && last.Delete > 0 {
+		last.Delete += n
+		return
+	}
+	b.edit = append(b.edit, TextOp{Delete: n})
+}
+
+func (b *editBuilder) insert(s string) {
+	if s == "" {
+		return
+	}
+
+	last := b.last()
+	if last != nil && last.Delete > 0 {
+		// Move the insert before the delete
+		if len(b.edit) >= 2 && b.edit[len(b.edit)-2].Insert != "" {
+			b.edit[len(b.edit)-2].Insert += s
+			return
+		}
+		b.edit = append(b.edit, *last)
+		b.edit[len(b.edit)-2] = TextOp{Insert: s}
+		return
+	}
+	if last != nil && last.Insert != "" {
+		last.Insert += s
+		return
+	}
+	b.edit = append(b.edit, TextOp{Insert: s})
+}
+
+// editReader hands out the components of an edit, splitting retains and deletes as needed
+type editReader struct {
+	edit TextEdit
+	i    int
+	// used is how much of the current retain or delete was already taken
+	used int
+}
+
+func (r *editReader) peek() (TextOp, bool) {
+	if r.i >= len(r.edit) {
+		return TextOp{}, false
+	}
+	op := r.edit[r.i]
+	if op.Retain > 0 {
+		op.Retain -= r.used
+	} else if op.Delete > 0 {
+		op.Delete -= r.used
+	}
+	return op, true
+}
+
+// take consumes n characters of the current retain or delete, or the whole current insert if n is 0
+func (r *editReader) take(n int) {
+	op := r.edit[r.i]
+	if n == 0 || r.used+n == op.Retain+op.Delete {
+		r.i++
+		r.used = 0
+		return
+	}
+	r.used += n
+}
+
+// transformText transforms two edits of the same text, so that applying a and then b', or b and then a', gives the same text.
+// Text inserted by a at the same position as text inserted by b comes first
+func transformText(a, b TextEdit) (aPrime, bPrime TextEdit, err error) {
+	if err := a.validate(); err != nil {
+		return nil, nil, err
+	}
+	if err := b.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	// Both edits implicitly retain the rest of the text, so they are padded to the same length
+	n := max(a.baseLength(), b.baseLength())
+	ra := editReader{edit: append(a[:len(a):len(a)], TextOp{Retain: n - a.baseLength() + 1})}
+	rb := editReader{edit: append(b[:len(b):len(b)], TextOp{Retain: n - b.baseLength() + 1})}
+
+	var ab, bb editBuilder
+	for {
+		opA, okA := ra.peek()
+		opB, okB := rb.peek()
+		if !okA || !okB {
+			break
+		}
+
+		switch {
+		case opA.Insert != "":
+			ab.insert(opA.Insert)
+			bb.retain(len([]rune(opA.Insert)))
+			ra.take(0)
+		case opB.Insert != "":
+			ab.retain(len([]rune(opB.Insert)))
+			bb.insert(opB.Insert)
+			rb.take(0)
+		default:
+			m := min(opA.Retain+opA.Delete, opB.Retain+opB.Delete)
+			switch {
+			case opA.Retain > 0 && opB.Retain > 0:
+				ab.retain(m)
+				bb.retain(m)
+			case opA.Delete > 0 && opB.Retain > 0:
+				ab.delete(m)
+			case opA.Retain > 0 && opB.Delete > 0:
+				bb.delete(m)
+			}
+			// Text both edits delete is already gone for the other one
+			ra.take(m)
+			rb.take(m)
+		}
+	}
+
+	return trimRetain(ab.edit), trimRetain(bb.edit), nil
+}
+
+// trimRetain drops a trailing retain, which is implied
+func trimRetain(e TextEdit) TextEdit {
+	if len(e) > 0 && e[len(e)-1].Retain > 0 {
+		e = e[:len(e)-1]
+	}
+	if e == nil {
+		e = TextEdit{}
+	}
+	return e
+}
+
+// diffText returns an edit that turns a into b. It keeps the longest common subsequence of characters if the changed range
+// is small enough, so other edits of the unchanged characters in between can be merged with it
+func diffText(a, b string) TextEdit {
+	ra, rb := []rune(a), []rune(b)
+
+	var prefix int
+	for prefix < len(ra) && prefix < len(rb) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	var suffix int
+	for suffix < len(ra)-prefix && suffix < len(rb)-prefix && ra[len(ra)-1-suffix] == rb[len(rb)-1-suffix] {
+		suffix++
+	}
+	ma, mb := ra[prefix:len(ra)-suffix], rb[prefix:len(rb)-suffix]
+
+	var eb editBuilder
+	eb.retain(prefix)
+
+	if len(ma) == 0 || len(mb) == 0 || (len(ma)+1)*(len(mb)+1) > maxDiffCells {
+		eb.insert(string(mb))
+		eb.delete(len(ma))
+		return trimRetain(eb.edit)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of ma[i:] and mb[j:]
+	w := len(mb) + 1
+	lcs := make([]int, (len(ma)+1)*w)
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+			} else {
+				lcs[i*w+j] = max(lcs[(i+1)*w+j], lcs[i*w+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			eb.retain(1)
+			i++
+			j++
+		case j < len(mb) && (i == len(ma) || lcs[i*w+j+1] >= lcs[(i+1)*w+j]):
+			eb.insert(string(mb[j]))
+			j++
+		default:
+			eb.delete(1)
+			i++
+		}
+	}
+
+	return trimRetain(eb.edit)
+}
@@ -0,0 +1,266 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+	// ErrConflict is returned if a change can't be undone or redone because the graph was changed since
+	ErrConflict = errors.New("conflicting change")
+)
+
+// undoLimit is how many changes per user and graph can be undone
+const undoLimit = 100
+
+// change is a group of operations a user applied at once, e.g. a layout, together with the operations that revert it
+type change struct {
+	ops     []*Operation
+	inverse []*Operation
+}
+
+// undoStacks holds the changes of one user on one graph. They are kept in memory only
+type undoStacks struct {
+	undo []*change
+	redo []*change
+}
+
+func (s *undoStacks) pushUndo(c *change) {
+	s.undo = append(s.undo, c)
+	if len(s.undo) > undoLimit {
+		s.undo = s.undo[len(s.undo)-undoLimit:]
+	}
+}
+
+func (s *undoStacks) pushRedo(c *change) {
+	s.redo = append(s.redo, c)
+	if len(s.redo) > undoLimit {
+		s.redo = s.redo[len(s.redo)-undoLimit:]
+	}
+}
+
+func popChange(stack *[]*change) *change {
+	if len(*stack) == 0 {
+		return nil
+	}
+	c := (*stack)[len(*stack)-1]
+	*stack = (*stack)[:len(*stack)-1]
+	return c
+}
+
+// stacks returns the undo and redo stacks of a user. Callers must hold the write lock
+func (g *Graph) stacks(userID int) *undoStacks {
+	if g.undo == nil {
+		g.undo = make(map[int]*undoStacks)
+	}
+	s, ok := g.undo[userID]
+	if !ok {
+		s = &undoStacks{}
+		g.undo[userID] = s
+	}
+	return s
+}
+
+// cloneStacks copies the undo and redo stacks of all users, so they can be restored if a change can't be saved.
+// Callers must hold the write lock
+func (g *Graph) cloneStacks() map[int]*undoStacks {
+	var stacks = make(map[int]*undoStacks, len(g.undo))
+	for userID, s := range g.undo {
+		stacks[userID] = &undoStacks{undo: slices.Clone(s.undo), redo: slices.Clone(s.redo)}
+	}
+	return stacks
+}
+
+// record adds a change made by a user to their undo stack. A new change can't be combined with the undone ones, so they are dropped.
+// Changes without a user can't be undone. Callers must hold the write lock
+func (g *Graph) record(c *change) {
+	if len(c.ops) == 0 || c.ops[0].UserID == 0 {
+		return
+	}
+
+	s := g.stacks(c.ops[0].UserID)
+	s.pushUndo(c)
+	s.redo = nil
+}
+
+// clone returns a deep copy of the operation, so it can be applied again without changing the original
+func (op *Operation) clone() *Operation {
+	c := *op
+	if op.Node != nil {
+		node := *op.Node
+		c.Node = &node
+	}
+	if op.Edge != nil {
+		edge := *op.Edge
+		c.Edge = &edge
+	}
+	if op.Position != nil {
+		position := *op.Position
+		c.Position = &position
+	}
+	if op.Content != nil {
+		content := *op.Content
+		c.Content = &content
+	}
+	c.ContentEdit = slices.Clone(op.ContentEdit)
+	return &c
+}
+
+// Inverse returns the operations that revert op, based on the current state of the graph. It must be called before
+// op is applied. Added nodes and edges without an ID get one, so the inverse can refer to them.
+// Removing a node also removes its edges, so its inverse adds them back. Callers must hold at least a read lock
+func (g *Graph) Inverse(op *Operation) ([]*Operation, error) {
+	switch op.Type {
+	case OpAddNode:
+		if op.Node == nil {
+			return nil, fmt.Errorf("%w: %s requires a node", ErrInvalid, op.Type)
+		}
+		if op.Node.ID == "" {
+			op.Node.ID = uuid.NewString()
+		}
+		return []*Operation{{Type: OpRemoveNode, NodeID: op.Node.ID}}, nil
+	case OpUpdateNode:
+		n := g.Node(op.NodeID)
+		if n == nil {
+			return nil, ErrNodeNotFound
+		}
+		inverse := &Operation{Type: OpUpdateNode, NodeID: n.ID}
+		if op.Position != nil {
+			position := n.Position
+			inverse.Position = &position
+		}
+		if op.Content != nil || op.ContentEdit != nil {
+			content := n.Content
+			inverse.Content = &content
+		}
+		return []*Operation{inverse}, nil
+	case OpRemoveNode:
+		n := g.Node(op.NodeID)
+		if n == nil {
+			return nil, ErrNodeNotFound
+		}
+		node := *n
+		inverse := []*Operation{{Type: OpAddNode, Node: &node}}
+		for _, e := range g.Edges {
+			if e.Source == n.ID || e.Target == n.ID {
+				edge := *e
+				inverse = append(inverse, &Operation{Type: OpAddEdge, Edge: &edge})
+			}
+		}
+		return inverse, nil
+	case OpAddEdge:
+		if op.Edge == nil {
+			return nil, fmt.Errorf("%w: %s requires an edge", ErrInvalid, op.Type)
+		}
+		if op.Edge.ID == "" {
+			op.Edge.ID = uuid.NewString()
+		}
+		return []*Operation{{Type: OpRemoveEdge, EdgeID: op.Edge.ID}}, nil
+	case OpUpdateEdge:
+		if op.Edge == nil {
+			return nil, fmt.Errorf("%w: %s requires an edge", ErrInvalid, op.Type)
+		}
+		e := g.Edge(op.Edge.ID)
+		if e == nil {
+			return nil, ErrEdgeNotFound
+		}
+		edge := *e
+		return []*Operation{{Type: OpUpdateEdge, Edge: &edge}}, nil
+	case OpRemoveEdge:
+		e := g.Edge(op.EdgeID)
+		if e == nil {
+			return nil, ErrEdgeNotFound
+		}
+		edge := *e
+		return []*Operation{{Type: OpAddEdge, Edge: &edge}}, nil
+	case OpRename:
+		return []*Operation{{Type: OpRename, Name: g.Name}}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown operation type %q", ErrInvalid, op.Type)
+	}
+}
+
+// Undo reverts the user's last change to the graph. The reverting operations are applied and broadcast like any
+// other change and can be redone. If the graph was changed in a way that makes the change impossible to revert,
+// e.g. another user removed a node it moved, ErrConflict is returned and the change is dropped from the stack
+func (m *Manager) Undo(id string, userID int, clientID string) ([]*Operation, error) {
+	return m.revert(id, userID, clientID, false)
+}
+
+// Redo applies the user's last undone change again
+func (m *Manager) Redo(id string, userID int, clientID string) ([]*Operation, error) {
+	return m.revert(id, userID, clientID, true)
+}
+
+// UndoDepth returns how many changes the user can undo and redo on the graph
+func (m *Manager) UndoDepth(id string, userID int) (undo, redo int, err error) {
+	err = m.View(id, func(g *Graph) error {
+		if s, ok := g.undo[userID]; ok {
+			undo, redo = len(s.undo), len(s.redo)
+		}
+		return nil
+	})
+	return undo, redo, err
+}
+
+// revert pops a change from the undo or redo stack, applies its inverse and pushes the result onto the other stack.
+// Undoing the inverse of a change is the same as redoing it, so both directions work alike
+func (m *Manager) revert(id string, userID int, clientID string, redo bool) ([]*Operation, error) {
+	var applied []*Operation
+	err := m.update(id, func(g *Graph) (*change, error) {
+		s := g.stacks(userID)
+
+		var c *change
+		if redo {
+			if c = popChange(&s.redo); c == nil {
+				return nil, ErrNothingToRedo
+			}
+		} else {
+			if c = popChange(&s.undo); c == nil {
+				return nil, ErrNothingToUndo
+			}
+		}
+
+		var ops = make([]*Operation, 0, len(c.inverse))
+		for _, op := range c.inverse {
+			op = op.clone()
+			op.Version = 0
+			op.ClientID = clientID
+			op.UserID = userID
+			ops = append(ops, op)
+		}
+
+		// Try the operations on a copy first, so a conflict leaves the graph unchanged
+		trial := g.Clone()
+		for _, op := range ops {
+			if err := trial.Apply(op.clone()); err != nil {
+				return nil, fmt.Errorf("%w: the graph was changed since: %v", ErrConflict, err)
+			}
+		}
+
+		reverted := &change{}
+		for _, op := range ops {
+			inverse, err := g.commit(op)
+			if err != nil {
+				return nil, err
+			}
+			reverted.ops = append(reverted.ops, op)
+			reverted.inverse = append(inverse, reverted.inverse...)
+		}
+
+		if redo {
+			s.pushUndo(reverted)
+		} else {
+			s.pushRedo(reverted)
+		}
+
+		applied = reverted.ops
+		return reverted, nil
+	})
+	return applied, err
+}
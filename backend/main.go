@@ -2,10 +2,11 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
 	"pathflux/config"
+	"pathflux/events"
+	"pathflux/graph"
 	"pathflux/meili"
 	"pathflux/web"
 )
@@ -28,22 +29,29 @@ func main() {
 
 	dbLogger := log.New(logger.Writer(), "[meili] ", log.LstdFlags)
 
-	client, err := meili.NewDBClient(ctx, cfg.GitLab, dbLogger, meiliHost, meiliAPIKey, func(items []meili.GitLabItem) {
-		for _, item := range items {
-			fmt.Println(item.Title)
-		}
-	}, func(items []meili.User) {
-		for _, item := range items {
-			fmt.Println(item.Name)
-		}
-	})
+	hub := events.NewHub()
+
+	client, err := meili.NewDBClient(ctx, cfg.GitLab, dbLogger, meiliHost, meiliAPIKey, hub.PublishItems, hub.PublishUsers, hub.PublishRemovedItems)
 	if err != nil {
 		log.Fatalf("failed to create MeiliSearch client: %v", err)
 	}
+	hub.SetAccess(client)
+
+	graphStorage, err := graph.NewFileStorage(cfg.GraphDataDir)
+	if err != nil {
+		log.Fatalf("failed to set up graph storage: %v", err)
+	}
+
+	graphs, err := graph.NewManager(graphStorage)
+	if err != nil {
+		log.Fatalf("failed to load graphs: %v", err)
+	}
 
 	server := &web.Server{
-		Cfg: cfg,
-		DB:  client,
+		Cfg:    cfg,
+		DB:     client,
+		Graphs: graphs,
+		Events: hub,
 	}
 
 	if err := server.Run(); err != nil {
@@ -0,0 +1,201 @@
+package meili
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// accessRefreshCooldown limits how often an unknown user can trigger a membership refresh
+const accessRefreshCooldown = time.Minute
+
+// userAccess lists what a single user can see
+type userAccess struct {
+	// projectIDs are the projects the user is a direct or inherited member of
+	projectIDs []int
+	// groupIDs are the configured groups the user is a member of, which grants access to their epics
+	groupIDs []int
+}
+
+// accessCache holds the memberships of all users in the synced groups
+type accessCache struct {
+	lock sync.RWMutex
+
+	users       map[int]userAccess
+	refreshedAt time.Time
+	refreshing  bool
+}
+
+// refreshAccess fetches the memberships of all projects and groups. It uses the service API key,
+// so it works without the user's token and keeps working after restarts. Groups and projects are fetched
+// in parallel, at most SyncConcurrency at a time
+func (c *DBClient) refreshAccess(ctx context.Context) (count int, err error) {
+	c.access.lock.Lock()
+	if c.access.refreshing {
+		c.access.lock.Unlock()
+		return 0, nil
+	}
+	c.access.refreshing = true
+	c.access.lock.Unlock()
+
+	defer func() {
+		c.access.lock.Lock()
+		c.access.refreshing = false
+		c.access.lock.Unlock()
+	}()
+
+	groupList := c.groupList()
+	var groupMembers = make([][]*gitlab.GroupMember, len(groupList))
+	var groupProjects = make([][]*gitlab.Project, len(groupList))
+	err = forEachParallel(ctx, c.gitlabConfig.SyncConcurrency, groupList, func(ctx context.Context, i int, group *gitlab.Group) (err error) {
+		groupMembers[i], err = listAllGroupMembers(c.gitlabClient, group.ID, gitlab.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to get members of group %q: %w", group.Name, err)
+		}
+		groupProjects[i], err = listAllGroupProjects(c.gitlabClient, group.ID, gitlab.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to get projects of group %q: %w", group.Name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Synced groups can contain each other, so their projects are only fetched once
+	var projectList []*gitlab.Project
+	var seenProjects = make(map[int]bool)
+	for _, list := range groupProjects {
+		for _, project := range list {
+			if !seenProjects[project.ID] {
+				seenProjects[project.ID] = true
+				projectList = append(projectList, project)
+			}
+		}
+	}
+
+	var projectMembers = make([][]*gitlab.ProjectMember, len(projectList))
+	err = forEachParallel(ctx, c.gitlabConfig.SyncConcurrency, projectList, func(ctx context.Context, i int, project *gitlab.Project) (err error) {
+		projectMembers[i], err = listAllProjectMembers(c.gitlabClient, project.ID, gitlab.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to get members of project %q: %w", project.PathWithNamespace, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var projects = make(map[int]map[int]struct{})
+	var groups = make(map[int]map[int]struct{})
+
+	var add = func(m map[int]map[int]struct{}, userID, id int) {
+		if m[userID] == nil {
+			m[userID] = make(map[int]struct{})
+		}
+		m[userID][id] = struct{}{}
+	}
+
+	for i, group := range groupList {
+		for _, member := range groupMembers[i] {
+			add(groups, member.ID, group.ID)
+		}
+	}
+	for i, project := range projectList {
+		for _, member := range projectMembers[i] {
+			add(projects, member.ID, project.ID)
+		}
+	}
+
+	var users = make(map[int]userAccess)
+	var sortedKeys = func(m map[int]struct{}) []int {
+		var keys = make([]int, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Ints(keys)
+		return keys
+	}
+	for userID, ids := range projects {
+		access := users[userID]
+		access.projectIDs = sortedKeys(ids)
+		users[userID] = access
+	}
+	for userID, ids := range groups {
+		access := users[userID]
+		access.groupIDs = sortedKeys(ids)
+		users[userID] = access
+	}
+
+	c.access.lock.Lock()
+	c.access.users = users
+	c.access.refreshedAt = time.Now()
+	c.access.lock.Unlock()
+
+	return len(users), nil
+}
+
+func joinInts(ints []int) string {
+	var s = make([]string, 0, len(ints))
+	for _, i := range ints {
+		s = append(s, strconv.Itoa(i))
+	}
+	return strings.Join(s, ", ")
+}
+
+// accessOf returns what the user can see. Unknown users trigger a refresh of the memberships, as they might have joined since
+func (c *DBClient) accessOf(userID int) userAccess {
+	c.access.lock.RLock()
+	access, known := c.access.users[userID]
+	stale := time.Since(c.access.refreshedAt) > accessRefreshCooldown
+	c.access.lock.RUnlock()
+
+	if !known && stale {
+		go func() {
+			if _, err := c.refreshAccess(context.Background()); err != nil {
+				c.logger.Printf("Failed to refresh memberships: %v", err)
+			}
+		}()
+	}
+
+	return access
+}
+
+// VisibilityFilter returns a Meili filter that matches the items the user can see in GitLab.
+// ok is false if the user can't see any item
+func (c *DBClient) VisibilityFilter(userID int) (filter string, ok bool) {
+	access := c.accessOf(userID)
+
+	var parts []string
+	if len(access.projectIDs) > 0 {
+		parts = append(parts, "project_id IN ["+joinInts(access.projectIDs)+"]")
+	}
+	if len(access.groupIDs) > 0 {
+		parts = append(parts, fmt.Sprintf("(kind = %s AND group_id IN [%s])", ItemKindEpic, joinInts(access.groupIDs)))
+	}
+
+	if len(parts) == 0 {
+		return "", false
+	}
+
+	return strings.Join(parts, " OR "), true
+}
+
+// CanSee checks whether the user can see the item in GitLab, matching the same items as VisibilityFilter
+func (c *DBClient) CanSee(userID int, item GitLabItem) bool {
+	access := c.accessOf(userID)
+
+	if item.Kind == ItemKindEpic {
+		_, found := slices.BinarySearch(access.groupIDs, item.GroupID)
+		return found
+	}
+	_, found := slices.BinarySearch(access.projectIDs, item.ProjectID)
+	return found
+}
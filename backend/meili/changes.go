@@ -0,0 +1,86 @@
+package meili
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// changeBatchSize is how many documents are compared with the index per request during syncs
+const changeBatchSize = 1000
+
+// fetchDocuments returns the indexed documents whose ID is one of the given filter values, keyed by key.
+// They are fetched in batches, so comparing many documents with the index costs a few requests instead of one per document
+func fetchDocuments[T any](ctx context.Context, index meilisearch.IndexManager, ids []string, key func(T) string) (map[string]T, error) {
+	var documents = make(map[string]T, len(ids))
+
+	for start := 0; start < len(ids); start += changeBatchSize {
+		batch := ids[start:min(start+changeBatchSize, len(ids))]
+
+		var resp meilisearch.DocumentsResult
+		err := index.GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{
+			Limit:  int64(len(batch)),
+			Filter: "id IN [" + strings.Join(batch, ", ") + "]",
+		}, &resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get documents: %w", err)
+		}
+
+		var decoded []T
+		if err := decodeDocuments(resp.Results, &decoded); err != nil {
+			return nil, err
+		}
+
+		for _, doc := range decoded {
+			documents[key(doc)] = doc
+		}
+	}
+
+	return documents, nil
+}
+
+// changedItems returns the items that are not indexed or differ from their indexed version
+func (c *DBClient) changedItems(ctx context.Context, items []GitLabItem) ([]GitLabItem, error) {
+	var ids = make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, quoteFilterValue(item.ID))
+	}
+
+	existing, err := fetchDocuments(ctx, c.client.Index(ITEMS_INDEX), ids, func(item GitLabItem) string { return item.ID })
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []GitLabItem
+	for _, item := range items {
+		if existingItem, ok := existing[item.ID]; !ok || !reflect.DeepEqual(existingItem, item) {
+			changed = append(changed, item)
+		}
+	}
+	return changed, nil
+}
+
+// changedUsers returns the users that are not indexed or differ from their indexed version
+func (c *DBClient) changedUsers(ctx context.Context, users []User) ([]User, error) {
+	var ids = make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, strconv.Itoa(user.GitlabID))
+	}
+
+	existing, err := fetchDocuments(ctx, c.client.Index(USERS_INDEX), ids, func(user User) string { return strconv.Itoa(user.GitlabID) })
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []User
+	for _, user := range users {
+		if existingUser, ok := existing[strconv.Itoa(user.GitlabID)]; !ok || existingUser != user {
+			changed = append(changed, user)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,237 @@
+package meili
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// fakeMeili serves the document endpoints used during syncs from memory and counts the requests it gets
+type fakeMeili struct {
+	mu        sync.Mutex
+	documents map[string]map[string]map[string]any
+	requests  atomic.Int64
+}
+
+func newFakeMeili(tb testing.TB) (*fakeMeili, *DBClient) {
+	fake := &fakeMeili{documents: make(map[string]map[string]map[string]any)}
+	server := httptest.NewServer(fake)
+	tb.Cleanup(server.Close)
+
+	return fake, &DBClient{client: meilisearch.New(server.URL)}
+}
+
+// add stores documents in an index the way Meili returns them
+func (f *fakeMeili) add(tb testing.TB, index string, documents any) {
+	data, err := json.Marshal(documents)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		tb.Fatal(err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.documents[index] == nil {
+		f.documents[index] = make(map[string]map[string]any)
+	}
+	for _, doc := range decoded {
+		f.documents[index][fmt.Sprint(doc["id"])] = doc
+	}
+}
+
+func (f *fakeMeili) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests.Add(1)
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 3 || parts[0] != "indexes" || parts[2] != "documents" {
+		http.NotFound(w, r)
+		return
+	}
+
+	f.mu.Lock()
+	index := f.documents[parts[1]]
+	f.mu.Unlock()
+
+	switch {
+	case len(parts) == 4 && parts[3] == "fetch" && r.Method == http.MethodPost:
+		var query struct {
+			Limit  int    `json:"limit"`
+			Filter string `json:"filter"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var results = []map[string]any{}
+		for _, id := range filterIDs(query.Filter) {
+			if doc, ok := index[id]; ok && len(results) < query.Limit {
+				results = append(results, doc)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"results": results, "limit": query.Limit, "offset": 0, "total": len(results)})
+	case len(parts) == 4 && r.Method == http.MethodGet:
+		doc, ok := index[parts[3]]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"message": "document not found", "code": "document_not_found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, doc)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// filterIDs parses the values of an `id IN [...]` filter
+func filterIDs(filter string) []string {
+	start, end := strings.Index(filter, "["), strings.LastIndex(filter, "]")
+	if start < 0 || end < start {
+		return nil
+	}
+
+	var ids []string
+	for _, value := range strings.Split(filter[start+1:end], ", ") {
+		value = strings.TrimSuffix(strings.TrimPrefix(value, `"`), `"`)
+		ids = append(ids, strings.ReplaceAll(value, `\"`, `"`))
+	}
+	return ids
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// testItems returns n items of which every tenth differs from the indexed version and every twentieth is not indexed
+func testItems(n int) (items, indexed []GitLabItem) {
+	for i := range n {
+		item := GitLabItem{
+			ID:          fmt.Sprintf("issue-%d", i),
+			GroupID:     1,
+			ProjectID:   i%7 + 1,
+			Kind:        "issue",
+			Slug:        fmt.Sprintf("group/project#%d", i),
+			Labels:      []Label{},
+			Title:       fmt.Sprintf("Issue %d", i),
+			Description: strings.Repeat("description ", 20),
+			IID:         i,
+			State:       "opened",
+			Relations:   []Relation{},
+		}
+		items = append(items, item)
+
+		switch {
+		case i%20 == 0:
+		case i%10 == 0:
+			stale := item
+			stale.Title += " (old)"
+			indexed = append(indexed, stale)
+		default:
+			indexed = append(indexed, item)
+		}
+	}
+	return items, indexed
+}
+
+func TestChangedItems(t *testing.T) {
+	fake, c := newFakeMeili(t)
+	items, indexed := testItems(2500)
+	fake.add(t, ITEMS_INDEX, indexed)
+
+	changed, err := c.changedItems(context.Background(), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changed) != 250 {
+		t.Errorf("got %d changed items, want 250", len(changed))
+	}
+	for _, item := range changed {
+		var i int
+		fmt.Sscanf(item.ID, "issue-%d", &i)
+		if i%10 != 0 {
+			t.Errorf("item %s is unchanged but was returned", item.ID)
+		}
+	}
+	if got := fake.requests.Load(); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+}
+
+func TestChangedUsers(t *testing.T) {
+	fake, c := newFakeMeili(t)
+
+	var users, indexed []User
+	for i := 1; i <= 100; i++ {
+		user := User{GitlabID: i, Username: fmt.Sprintf("user%d", i), Name: fmt.Sprintf("User %d", i), State: "active"}
+		users = append(users, user)
+		if i%2 == 0 {
+			indexed = append(indexed, user)
+		}
+	}
+	fake.add(t, USERS_INDEX, indexed)
+
+	changed, err := c.changedUsers(context.Background(), users)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changed) != 50 {
+		t.Errorf("got %d changed users, want 50", len(changed))
+	}
+	if got := fake.requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
+
+// BenchmarkChangeDetection compares the batched change detection with fetching every document on its own, as syncs did before
+func BenchmarkChangeDetection(b *testing.B) {
+	for _, n := range []int{100, 1000, 5000} {
+		items, indexed := testItems(n)
+
+		b.Run(fmt.Sprintf("batched/%d", n), func(b *testing.B) {
+			fake, c := newFakeMeili(b)
+			fake.add(b, ITEMS_INDEX, indexed)
+			b.ResetTimer()
+
+			for range b.N {
+				if _, err := c.changedItems(context.Background(), items); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(fake.requests.Load())/float64(b.N), "requests/op")
+		})
+
+		b.Run(fmt.Sprintf("per-document/%d", n), func(b *testing.B) {
+			fake, c := newFakeMeili(b)
+			fake.add(b, ITEMS_INDEX, indexed)
+			index := c.client.Index(ITEMS_INDEX)
+			b.ResetTimer()
+
+			for range b.N {
+				for _, item := range items {
+					var existing GitLabItem
+					err := index.GetDocumentWithContext(context.Background(), item.ID, nil, &existing)
+					var meiliErr *meilisearch.Error
+					if err != nil && !(errors.As(err, &meiliErr) && meiliErr.StatusCode == http.StatusNotFound) {
+						b.Fatal(err)
+					}
+				}
+			}
+			b.ReportMetric(float64(fake.requests.Load())/float64(b.N), "requests/op")
+		})
+	}
+}
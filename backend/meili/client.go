@@ -3,6 +3,7 @@ package meili
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -24,6 +25,7 @@ const (
 
 type ItemUpdateCallback func(items []GitLabItem)
 type UserUpdateCallback func(users []User)
+type ItemRemoveCallback func(ids []string)
 
 type DBClient struct {
 	logger       *log.Logger
@@ -33,12 +35,20 @@ type DBClient struct {
 
 	groups map[int]*gitlab.Group
 
+	// access caches which projects and groups each user can see
+	access accessCache
+
 	// Gets called when an item change is noticed
 	updateItemCallback  ItemUpdateCallback
 	updateUsersCallback UserUpdateCallback
+	// Gets called with the IDs of items that were removed from the index
+	removeItemCallback ItemRemoveCallback
+
+	// epicSyncRequests wakes up the worker that syncs epics for webhooks, see requestEpicSync
+	epicSyncRequests chan struct{}
 }
 
-func NewDBClient(ctx context.Context, gitlabConfig config.GitLab, logger *log.Logger, meiliHost, meiliAPIKey string, onUpdateItem ItemUpdateCallback, onUpdateUser UserUpdateCallback) (client *DBClient, err error) {
+func NewDBClient(ctx context.Context, gitlabConfig config.GitLab, logger *log.Logger, meiliHost, meiliAPIKey string, onUpdateItem ItemUpdateCallback, onUpdateUser UserUpdateCallback, onRemoveItem ItemRemoveCallback) (client *DBClient, err error) {
 	var httpClient = &http.Client{
 		Timeout: 1 * time.Minute,
 	}
@@ -52,7 +62,7 @@ func NewDBClient(ctx context.Context, gitlabConfig config.GitLab, logger *log.Lo
 		USERS_INDEX,
 		"id",
 		[]string{"name", "username", "bio", "id"},
-		nil,
+		[]string{"id"},
 		nil,
 	)
 	if err != nil {
@@ -66,7 +76,7 @@ func NewDBClient(ctx context.Context, gitlabConfig config.GitLab, logger *log.Lo
 		ITEMS_INDEX,
 		"id",
 		[]string{"title", "slug", "iid", "description", "labels.name", "involved_users.username", "involved_users.name", "state", "kind"},
-		[]string{"group_id", "kind", "state", "updated_at"},
+		[]string{"id", "slug", "iid", "group_id", "project_id", "kind", "state", "updated_at", "labels.name", "involved_users.username", "created_at_ts", "updated_at_ts", "closed_at_ts", "relations", "relations.item_id", "time_estimate"},
 		[]string{"updated_at"},
 	)
 	if err != nil {
@@ -97,9 +107,12 @@ func NewDBClient(ctx context.Context, gitlabConfig config.GitLab, logger *log.Lo
 		groups:              groups,
 		updateItemCallback:  onUpdateItem,
 		updateUsersCallback: onUpdateUser,
+		removeItemCallback:  onRemoveItem,
+		epicSyncRequests:    make(chan struct{}, 1),
 	}
 
 	go client.syncInBackground(ctx)
+	go client.syncEpicsInBackground(ctx)
 
 	return
 }
@@ -188,6 +201,9 @@ func (c *DBClient) syncInBackground(ctx context.Context) {
 	// timer will fire immediately, and later we adjust to user requested time
 	userUpdateTimer := time.NewTimer(0)
 	groupItemsTimer := time.NewTimer(0)
+	accessTimer := time.NewTimer(0)
+	// reconciliation is expensive and only finds removals, so it doesn't need to run on startup
+	reconcileTimer := time.NewTimer(c.gitlabConfig.ItemReconcileInterval)
 
 	for {
 		select {
@@ -199,7 +215,7 @@ func (c *DBClient) syncInBackground(ctx context.Context) {
 		case <-userUpdateTimer.C:
 			c.logger.Printf("Syncing users from %d GitLab group(s)", len(c.groups))
 
-			count, err := c.syncUsers()
+			count, err := c.syncUsers(ctx)
 			if err != nil {
 				c.logger.Printf("Failed to sync users: %v", err)
 			}
@@ -210,22 +226,54 @@ func (c *DBClient) syncInBackground(ctx context.Context) {
 		case <-groupItemsTimer.C:
 			c.logger.Printf("Syncing items from %d GitLab group(s)", len(c.groups))
 
-			var total int
-			for _, group := range c.groups {
-				count, err := c.syncGroupItems(group)
+			// Errors are logged per group, so only a cancelled context is returned
+			groups := c.groupList()
+			var counts = make([]int, len(groups))
+			_ = forEachParallel(ctx, c.gitlabConfig.SyncConcurrency, groups, func(ctx context.Context, i int, group *gitlab.Group) error {
+				count, err := c.syncGroupItems(ctx, group)
 				if err != nil {
 					c.logger.Printf("Error while syncing items for group %q: %v", group.Name, err)
 				}
 
-				total += count
+				counts[i] = count
 				c.logger.Printf("Synced %d item updates for group %q", count, group.Name)
-			}
+				return nil
+			})
 
 			if len(c.groups) > 1 {
+				var total int
+				for _, count := range counts {
+					total += count
+				}
 				c.logger.Printf("Synced %d item updates in total", total)
 			}
 
 			groupItemsTimer.Reset(c.gitlabConfig.ItemUpdateInterval)
+		case <-accessTimer.C:
+			count, err := c.refreshAccess(ctx)
+			if err != nil {
+				c.logger.Printf("Failed to refresh memberships: %v", err)
+			} else {
+				c.logger.Printf("Refreshed memberships of %d users", count)
+			}
+
+			accessTimer.Reset(c.gitlabConfig.MembershipUpdateInterval)
+		case <-reconcileTimer.C:
+			c.logger.Printf("Reconciling items of %d GitLab group(s)", len(c.groups))
+
+			_ = forEachParallel(ctx, c.gitlabConfig.SyncConcurrency, c.groupList(), func(ctx context.Context, _ int, group *gitlab.Group) error {
+				count, err := c.reconcileGroupItems(ctx, group)
+				if err != nil {
+					c.logger.Printf("Error while reconciling items for group %q: %v", group.Name, err)
+				}
+
+				if count > 0 {
+					c.logger.Printf("Removed %d stale items of group %q", count, group.Name)
+				}
+				return nil
+			})
+
+			reconcileTimer.Reset(c.gitlabConfig.ItemReconcileInterval)
 		}
 	}
 }
@@ -260,57 +308,71 @@ func FromGitLabGroupMember(user *gitlab.GroupMember) User {
 	}
 }
 
-func (c *DBClient) syncUsers() (count int, err error) {
+func (c *DBClient) syncUsers(ctx context.Context) (count int, err error) {
 	var users []User
 	var seenIDs = make(map[int]struct{})
 
-	index := c.client.Index(USERS_INDEX)
-
-	for _, group := range c.groups {
-		members, err := listAllGroupMembers(c.gitlabClient, group.ID)
+	groups := c.groupList()
+	var groupMembers = make([][]*gitlab.GroupMember, len(groups))
+	err = forEachParallel(ctx, c.gitlabConfig.SyncConcurrency, groups, func(ctx context.Context, i int, group *gitlab.Group) (err error) {
+		groupMembers[i], err = listAllGroupMembers(c.gitlabClient, group.ID, gitlab.WithContext(ctx))
 		if err != nil {
-			return 0, fmt.Errorf("failed to get group members for group %q: %w", group.Name, err)
+			return fmt.Errorf("failed to get group members for group %q: %w", group.Name, err)
 		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
 
+	for _, members := range groupMembers {
 		for _, member := range members {
 			if _, ok := seenIDs[member.ID]; ok {
 				continue
 			}
 			seenIDs[member.ID] = struct{}{}
 
-			user := FromGitLabGroupMember(member)
-
-			var meiliUser User
-			err = index.GetDocument(strconv.Itoa(member.ID), &meilisearch.DocumentQuery{
-				Fields: []string{"*"},
-			}, &meiliUser)
-			if err != nil || meiliUser != user {
-				users = append(users, user)
-			}
+			users = append(users, FromGitLabGroupMember(member))
 		}
 	}
 
+	users, err = c.changedUsers(ctx, users)
+	if err != nil {
+		return 0, err
+	}
+
 	if len(users) == 0 {
 		return 0, nil
 	}
 
+	if err := c.upsertUsers(users); err != nil {
+		return 0, err
+	}
+
+	return len(users), nil
+}
+
+// upsertUsers adds or replaces the users in the index and notifies the user callback
+func (c *DBClient) upsertUsers(users []User) error {
+	index := c.client.Index(USERS_INDEX)
+
 	task, err := index.AddDocuments(users)
 	if err != nil {
-		return 0, fmt.Errorf("failed to add users: %w", err)
+		return fmt.Errorf("failed to add users: %w", err)
 	}
 
 	res, err := c.client.WaitForTask(task.TaskUID, 0)
 	if err != nil {
-		return 0, fmt.Errorf("failed to wait for task: %w", err)
+		return fmt.Errorf("failed to wait for task: %w", err)
 	}
 
 	if res.Status != meilisearch.TaskStatusSucceeded {
-		return 0, fmt.Errorf("user add task was not successful: %q", res.Status)
+		return fmt.Errorf("user add task was not successful: %q", res.Status)
 	}
 
 	c.updateUsersCallback(users)
 
-	return len(users), nil
+	return nil
 }
 
 type ItemKind string
@@ -321,213 +383,102 @@ const (
 	ItemKindEpic         ItemKind = "epic"
 )
 
-func (c *DBClient) syncGroupItems(group *gitlab.Group) (count int, err error) {
-	// Get the last update time from the index to only fetch newer items
-	index := c.client.Index(ITEMS_INDEX)
-
-	var findNewestUpdate = func(kind ItemKind) *time.Time {
-		// Get the newest update time from the index
-		item, err := index.Search("", &meilisearch.SearchRequest{
-			Limit:                1,
-			AttributesToRetrieve: []string{"updated_at"},
-			Sort:                 []string{"updated_at:desc"},
-			Filter: []string{
-				fmt.Sprintf("group_id=%d", group.ID),
-				fmt.Sprintf("kind=%s", kind),
-			},
-		})
-		if err != nil {
-			c.logger.Printf("Failed to get newest update time for %q: %v", kind, err)
-			return nil
-		}
-
-		if len(item.Hits) != 1 {
-			return nil
-		}
+// idPrefix is the prefix of document IDs of this kind, as the GitLab IDs of different kinds can overlap
+func (k ItemKind) idPrefix() string {
+	switch k {
+	case ItemKindIssue:
+		return "i"
+	case ItemKindMergeRequest:
+		return "mr"
+	case ItemKindEpic:
+		return "e"
+	default:
+		return ""
+	}
+}
 
-		m, ok := item.Hits[0].(map[string]interface{})
-		if !ok {
-			return nil
-		}
+// ItemID returns the document ID of the item with the given kind and GitLab ID
+func ItemID(kind ItemKind, gitlabID int) string {
+	return kind.idPrefix() + strconv.Itoa(gitlabID)
+}
 
-		v, ok := m["updated_at"].(string)
-		if !ok {
-			return nil
+// ParseItemID splits a document ID like "i123", "mr45" or "e7" into kind and GitLab ID
+func ParseItemID(id string) (kind ItemKind, gitlabID int, ok bool) {
+	// mr must be checked before the single letter prefixes
+	for _, k := range []ItemKind{ItemKindMergeRequest, ItemKindIssue, ItemKindEpic} {
+		rest, found := strings.CutPrefix(id, k.idPrefix())
+		if !found {
+			continue
 		}
 
-		var t time.Time
-		err = json.Unmarshal([]byte("\""+v+"\""), &t)
-		if err != nil {
-			return nil
+		num, err := strconv.Atoi(rest)
+		if err != nil || num <= 0 || strconv.Itoa(num) != rest {
+			return "", 0, false
 		}
 
-		return &t
+		return k, num, true
 	}
 
-	// Get all items in parallel and handle any errors
-	issues, issueErr := listAllGroupIssues(c.gitlabClient, group.ID, findNewestUpdate(ItemKindIssue))
-	mergeRequests, prErr := listAllGroupMergeRequests(c.gitlabClient, group.ID, findNewestUpdate(ItemKindMergeRequest))
-	epics, epicErr := listAllGroupEpics(c.gitlabClient, group.ID, findNewestUpdate(ItemKindEpic))
+	return "", 0, false
+}
 
-	// Combine errors if any occurred
-	var combinedError error
-	for _, err := range []error{issueErr, prErr, epicErr} {
+func (c *DBClient) syncGroupItems(ctx context.Context, group *gitlab.Group) (count int, err error) {
+	// Get the last update time from the index to only fetch newer items
+	index := c.client.Index(ITEMS_INDEX)
+
+	// Get all kinds of items in parallel. If one kind fails, the items of the others are still indexed
+	var (
+		issues        []*gitlab.Issue
+		mergeRequests []*gitlab.BasicMergeRequest
+		epics         []*gitlab.Epic
+	)
+	kinds := []ItemKind{ItemKindIssue, ItemKindMergeRequest, ItemKindEpic}
+	combinedError := forEachParallel(ctx, len(kinds), kinds, func(ctx context.Context, _ int, kind ItemKind) (err error) {
+		updatedAfter := c.findNewestUpdate(index, group, kind)
+		switch kind {
+		case ItemKindIssue:
+			issues, err = listAllGroupIssues(c.gitlabClient, group.ID, updatedAfter, gitlab.WithContext(ctx))
+		case ItemKindMergeRequest:
+			mergeRequests, err = listAllGroupMergeRequests(c.gitlabClient, group.ID, updatedAfter, gitlab.WithContext(ctx))
+		case ItemKindEpic:
+			epics, err = listAllGroupEpics(c.gitlabClient, group.ID, updatedAfter, gitlab.WithContext(ctx))
+		}
 		if err != nil {
-			if combinedError == nil {
-				combinedError = err
-			} else {
-				combinedError = fmt.Errorf("%w; %v", combinedError, err)
-			}
+			return fmt.Errorf("failed to list %s items: %w", kind, err)
 		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		return 0, combinedError
 	}
 
-	var updatedItems []GitLabItem
-
-	// Process issues
+	var items = make([]GitLabItem, 0, len(issues)+len(mergeRequests)+len(epics))
 	for _, item := range issues {
-		var involvedUsers []User
-		if item.Author != nil {
-			involvedUsers = append(involvedUsers, User{
-				GitlabID:  item.Author.ID,
-				Username:  item.Author.Username,
-				Name:      item.Author.Name,
-				State:     item.Author.State,
-				AvatarURL: item.Author.AvatarURL,
-				WebURL:    item.Author.WebURL,
-			})
-		}
-		for _, assignee := range item.Assignees {
-			involvedUsers = append(involvedUsers, User{
-				GitlabID:  assignee.ID,
-				Username:  assignee.Username,
-				Name:      assignee.Name,
-				State:     assignee.State,
-				AvatarURL: assignee.AvatarURL,
-				WebURL:    assignee.WebURL,
-			})
-		}
-
-		outItem := GitLabItem{
-			ID:            "i" + strconv.Itoa(item.ID),
-			Kind:          ItemKindIssue,
-			InvolvedUsers: deduplicateUsers(involvedUsers),
-			WebURL:        item.WebURL,
-			Title:         item.Title,
-			Description:   item.Description,
-			IID:           item.IID,
-			State:         GitLabItemState(item.State),
-			CreatedAt:     item.CreatedAt,
-			UpdatedAt:     item.UpdatedAt,
-			ClosedAt:      item.ClosedAt,
-			Slug:          strings.Split(item.References.Full, "#")[0] + "#" + strconv.Itoa(item.IID),
-			Labels:        convertLabels(item.LabelDetails, item.Labels),
-			GroupID:       group.ID,
-		}
-
-		// Try to find the item in our index
-		var existingItem GitLabItem
-		err := index.GetDocument(outItem.ID, &meilisearch.DocumentQuery{
-			Fields: []string{"*"},
-		}, &existingItem)
-		// If item doesn't exist or has changed, add it to updates
-		if err != nil || !reflect.DeepEqual(existingItem, outItem) {
-			updatedItems = append(updatedItems, outItem)
-		}
+		items = append(items, FromGitLabIssue(item, group.ID))
 	}
-
-	// Process merge requests
 	for _, item := range mergeRequests {
-		var involvedUsers []User
-		if item.Author != nil {
-			involvedUsers = append(involvedUsers, User{
-				GitlabID:  item.Author.ID,
-				Username:  item.Author.Username,
-				Name:      item.Author.Name,
-				State:     item.Author.State,
-				AvatarURL: item.Author.AvatarURL,
-				WebURL:    item.Author.WebURL,
-			})
-		}
-
-		for _, assignee := range item.Assignees {
-			involvedUsers = append(involvedUsers, User{
-				GitlabID:  assignee.ID,
-				Username:  assignee.Username,
-				Name:      assignee.Name,
-				State:     assignee.State,
-				AvatarURL: assignee.AvatarURL,
-				WebURL:    assignee.WebURL,
-			})
-		}
-
-		outItem := GitLabItem{
-			ID:            "mr" + strconv.Itoa(item.ID),
-			Kind:          ItemKindMergeRequest,
-			InvolvedUsers: deduplicateUsers(involvedUsers),
-			WebURL:        item.WebURL,
-			Title:         item.Title,
-			Description:   item.Description,
-			IID:           item.IID,
-			CreatedAt:     item.CreatedAt,
-			UpdatedAt:     item.UpdatedAt,
-			ClosedAt:      item.ClosedAt,
-			State:         GitLabItemState(item.State),
-			Slug:          strings.Split(item.References.Full, "!")[0] + "!" + strconv.Itoa(item.IID),
-			Labels:        convertLabels(item.LabelDetails, item.Labels),
-			GroupID:       group.ID,
-		}
-
-		// Try to find the item in our index
-		var existingItem GitLabItem
-		err := index.GetDocument(outItem.ID, &meilisearch.DocumentQuery{
-			Fields: []string{"*"},
-		}, &existingItem)
-		// If item doesn't exist or has changed, add it to updates
-		if err != nil || !reflect.DeepEqual(existingItem, outItem) {
-			updatedItems = append(updatedItems, outItem)
-		}
+		items = append(items, FromGitLabMergeRequest(item, group.ID))
 	}
-
-	// Process epics
 	for _, item := range epics {
-		var involvedUsers []User
-		if item.Author != nil {
-			involvedUsers = append(involvedUsers, User{
-				GitlabID:  item.Author.ID,
-				Username:  item.Author.Username,
-				Name:      item.Author.Name,
-				State:     item.Author.State,
-				AvatarURL: item.Author.AvatarURL,
-				WebURL:    item.Author.WebURL,
-			})
-		}
+		items = append(items, FromGitLabEpic(item, group.ID))
+	}
 
-		outItem := GitLabItem{
-			ID:            "e" + strconv.Itoa(item.ID),
-			Kind:          ItemKindEpic,
-			InvolvedUsers: deduplicateUsers(involvedUsers),
-			WebURL:        item.WebURL,
-			Title:         item.Title,
-			Description:   item.Description,
-			IID:           item.IID,
-			CreatedAt:     item.CreatedAt,
-			UpdatedAt:     item.UpdatedAt,
-			ClosedAt:      item.ClosedAt,
-			State:         GitLabItemState(item.State),
-			Slug:          "&" + strconv.Itoa(item.IID),
-			Labels:        convertLabels(nil, item.Labels),
-			GroupID:       group.ID,
+	// Without their relations the items would compare as changed, and indexing them would drop the stored relations.
+	// A failed request doesn't hold up the whole group, those items keep the relations they have in the index
+	failed, relationsErr := c.addRelations(ctx, items)
+	if relationsErr != nil {
+		if ctx.Err() != nil {
+			return 0, errors.Join(combinedError, relationsErr)
 		}
-
-		// Try to find the item in our index
-		var existingItem GitLabItem
-		err := index.GetDocument(outItem.ID, &meilisearch.DocumentQuery{
-			Fields: []string{"*"},
-		}, &existingItem)
-		// If item doesn't exist or has changed, add it to updates
-		if err != nil || !reflect.DeepEqual(existingItem, outItem) {
-			updatedItems = append(updatedItems, outItem)
+		if err := c.keepIndexedRelations(ctx, items, failed); err != nil {
+			return 0, errors.Join(combinedError, relationsErr, err)
 		}
+		combinedError = errors.Join(combinedError, relationsErr)
+	}
+
+	updatedItems, err := c.changedItems(ctx, items)
+	if err != nil {
+		return 0, errors.Join(combinedError, err)
 	}
 
 	// If there are no items to update, return early
@@ -535,24 +486,232 @@ func (c *DBClient) syncGroupItems(group *gitlab.Group) (count int, err error) {
 		return 0, combinedError
 	}
 
-	// Add all updated items to the index
-	task, err := index.AddDocuments(updatedItems)
+	if err := c.upsertItems(updatedItems); err != nil {
+		return 0, err
+	}
+
+	return len(updatedItems), combinedError
+}
+
+// upsertItems adds or replaces the items in the index and notifies the item callback
+func (c *DBClient) upsertItems(items []GitLabItem) error {
+	index := c.client.Index(ITEMS_INDEX)
+
+	task, err := index.AddDocuments(items)
 	if err != nil {
-		return 0, fmt.Errorf("failed to add items: %w", err)
+		return fmt.Errorf("failed to add items: %w", err)
 	}
 
 	res, err := c.client.WaitForTask(task.TaskUID, 0)
 	if err != nil {
-		return 0, fmt.Errorf("failed to wait for task: %w", err)
+		return fmt.Errorf("failed to wait for task: %w", err)
 	}
 
 	if res.Status != meilisearch.TaskStatusSucceeded {
-		return 0, fmt.Errorf("item add task was not successful: %q", res.Status)
+		return fmt.Errorf("item add task was not successful: %q", res.Status)
 	}
 
-	c.updateItemCallback(updatedItems)
+	c.updateItemCallback(items)
 
-	return len(updatedItems), combinedError
+	return nil
+}
+
+// FromGitLabIssue converts an issue of the given group
+func FromGitLabIssue(item *gitlab.Issue, groupID int) GitLabItem {
+	var involvedUsers []User
+	if item.Author != nil {
+		involvedUsers = append(involvedUsers, User{
+			GitlabID:  item.Author.ID,
+			Username:  item.Author.Username,
+			Name:      item.Author.Name,
+			State:     item.Author.State,
+			AvatarURL: item.Author.AvatarURL,
+			WebURL:    item.Author.WebURL,
+		})
+	}
+	for _, assignee := range item.Assignees {
+		involvedUsers = append(involvedUsers, User{
+			GitlabID:  assignee.ID,
+			Username:  assignee.Username,
+			Name:      assignee.Name,
+			State:     assignee.State,
+			AvatarURL: assignee.AvatarURL,
+			WebURL:    assignee.WebURL,
+		})
+	}
+
+	return GitLabItem{
+		ID:            ItemID(ItemKindIssue, item.ID),
+		Kind:          ItemKindIssue,
+		InvolvedUsers: deduplicateUsers(involvedUsers),
+		WebURL:        item.WebURL,
+		Title:         item.Title,
+		Description:   item.Description,
+		IID:           item.IID,
+		State:         GitLabItemState(item.State),
+		CreatedAt:     item.CreatedAt,
+		UpdatedAt:     item.UpdatedAt,
+		ClosedAt:      item.ClosedAt,
+		Slug:          strings.Split(item.References.Full, "#")[0] + "#" + strconv.Itoa(item.IID),
+		Labels:        convertLabels(item.LabelDetails, item.Labels),
+		GroupID:       groupID,
+		ProjectID:     item.ProjectID,
+		CreatedAtTS:   unixTime(item.CreatedAt),
+		UpdatedAtTS:   unixTime(item.UpdatedAt),
+		ClosedAtTS:    unixTimePtr(item.ClosedAt),
+		Weight:        item.Weight,
+		TimeEstimate:  timeEstimate(item.TimeStats),
+		Relations:     issueRelations(item),
+	}
+}
+
+// FromGitLabMergeRequest converts a merge request of the given group
+func FromGitLabMergeRequest(item *gitlab.BasicMergeRequest, groupID int) GitLabItem {
+	var involvedUsers []User
+	if item.Author != nil {
+		involvedUsers = append(involvedUsers, User{
+			GitlabID:  item.Author.ID,
+			Username:  item.Author.Username,
+			Name:      item.Author.Name,
+			State:     item.Author.State,
+			AvatarURL: item.Author.AvatarURL,
+			WebURL:    item.Author.WebURL,
+		})
+	}
+
+	for _, assignee := range item.Assignees {
+		involvedUsers = append(involvedUsers, User{
+			GitlabID:  assignee.ID,
+			Username:  assignee.Username,
+			Name:      assignee.Name,
+			State:     assignee.State,
+			AvatarURL: assignee.AvatarURL,
+			WebURL:    assignee.WebURL,
+		})
+	}
+
+	return GitLabItem{
+		ID:            ItemID(ItemKindMergeRequest, item.ID),
+		Kind:          ItemKindMergeRequest,
+		InvolvedUsers: deduplicateUsers(involvedUsers),
+		WebURL:        item.WebURL,
+		Title:         item.Title,
+		Description:   item.Description,
+		IID:           item.IID,
+		CreatedAt:     item.CreatedAt,
+		UpdatedAt:     item.UpdatedAt,
+		ClosedAt:      item.ClosedAt,
+		State:         GitLabItemState(item.State),
+		Slug:          strings.Split(item.References.Full, "!")[0] + "!" + strconv.Itoa(item.IID),
+		Labels:        convertLabels(item.LabelDetails, item.Labels),
+		GroupID:       groupID,
+		ProjectID:     item.ProjectID,
+		CreatedAtTS:   unixTime(item.CreatedAt),
+		UpdatedAtTS:   unixTime(item.UpdatedAt),
+		ClosedAtTS:    unixTimePtr(item.ClosedAt),
+		TimeEstimate:  timeEstimate(item.TimeStats),
+	}
+}
+
+// FromGitLabEpic converts an epic of the given group
+func FromGitLabEpic(item *gitlab.Epic, groupID int) GitLabItem {
+	var involvedUsers []User
+	if item.Author != nil {
+		involvedUsers = append(involvedUsers, User{
+			GitlabID:  item.Author.ID,
+			Username:  item.Author.Username,
+			Name:      item.Author.Name,
+			State:     item.Author.State,
+			AvatarURL: item.Author.AvatarURL,
+			WebURL:    item.Author.WebURL,
+		})
+	}
+
+	return GitLabItem{
+		ID:            ItemID(ItemKindEpic, item.ID),
+		Kind:          ItemKindEpic,
+		InvolvedUsers: deduplicateUsers(involvedUsers),
+		WebURL:        item.WebURL,
+		Title:         item.Title,
+		Description:   item.Description,
+		IID:           item.IID,
+		CreatedAt:     item.CreatedAt,
+		UpdatedAt:     item.UpdatedAt,
+		ClosedAt:      item.ClosedAt,
+		State:         GitLabItemState(item.State),
+		Slug:          "&" + strconv.Itoa(item.IID),
+		Labels:        convertLabels(nil, item.Labels),
+		GroupID:       groupID,
+		CreatedAtTS:   unixTime(item.CreatedAt),
+		UpdatedAtTS:   unixTime(item.UpdatedAt),
+		ClosedAtTS:    unixTimePtr(item.ClosedAt),
+		Relations:     epicRelations(item),
+	}
+}
+
+// requiredItemAttributes are attributes that documents indexed by older versions can lack.
+// An incremental sync would never update these documents, so a full sync is done while any exist
+var requiredItemAttributes = []string{"project_id", "updated_at_ts", "relations", "time_estimate"}
+
+func missingAttributesFilter() string {
+	var parts = make([]string, 0, len(requiredItemAttributes))
+	for _, attr := range requiredItemAttributes {
+		parts = append(parts, attr+" NOT EXISTS")
+	}
+	return strings.Join(parts, " OR ")
+}
+
+// findNewestUpdate returns the newest update time of indexed items of this group and kind, or nil if there are none
+func (c *DBClient) findNewestUpdate(index meilisearch.IndexManager, group *gitlab.Group, kind ItemKind) *time.Time {
+	outdated, err := index.Search("", &meilisearch.SearchRequest{
+		Limit:                1,
+		AttributesToRetrieve: []string{"id"},
+		Filter: []string{
+			fmt.Sprintf("group_id=%d", group.ID),
+			fmt.Sprintf("kind=%s", kind),
+			missingAttributesFilter(),
+		},
+	})
+	if err != nil || len(outdated.Hits) > 0 {
+		return nil
+	}
+
+	// Get the newest update time from the index
+	item, err := index.Search("", &meilisearch.SearchRequest{
+		Limit:                1,
+		AttributesToRetrieve: []string{"updated_at"},
+		Sort:                 []string{"updated_at:desc"},
+		Filter: []string{
+			fmt.Sprintf("group_id=%d", group.ID),
+			fmt.Sprintf("kind=%s", kind),
+		},
+	})
+	if err != nil {
+		c.logger.Printf("Failed to get newest update time for %q: %v", kind, err)
+		return nil
+	}
+
+	if len(item.Hits) != 1 {
+		return nil
+	}
+
+	m, ok := item.Hits[0].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	v, ok := m["updated_at"].(string)
+	if !ok {
+		return nil
+	}
+
+	var t time.Time
+	err = json.Unmarshal([]byte("\""+v+"\""), &t)
+	if err != nil {
+		return nil
+	}
+
+	return &t
 }
 
 func deduplicateUsers(users []User) []User {
@@ -654,6 +813,8 @@ type GitLabItem struct {
 	ID string `json:"id"`
 
 	GroupID int `json:"group_id"`
+	// ProjectID is the project of issues and merge requests, it is 0 for epics
+	ProjectID int `json:"project_id"`
 
 	Kind        ItemKind `json:"kind"`
 	WebURL      string   `json:"web_url"`
@@ -669,4 +830,39 @@ type GitLabItem struct {
 	CreatedAt *time.Time      `json:"created_at"`
 	UpdatedAt *time.Time      `json:"updated_at"`
 	ClosedAt  *time.Time      `json:"closed_at"`
+
+	// Unix timestamps of the dates above, as Meili can only filter numbers by range
+	CreatedAtTS int64  `json:"created_at_ts"`
+	UpdatedAtTS int64  `json:"updated_at_ts"`
+	ClosedAtTS  *int64 `json:"closed_at_ts,omitempty"`
+
+	// Weight is the issue weight, 0 if unset
+	Weight int `json:"weight"`
+	// TimeEstimate is the estimated time in seconds, 0 if unset
+	TimeEstimate int `json:"time_estimate"`
+
+	// Relations to other items, see Relation
+	Relations []Relation `json:"relations"`
+}
+
+func timeEstimate(stats *gitlab.TimeStats) int {
+	if stats == nil {
+		return 0
+	}
+	return stats.TimeEstimate
+}
+
+func unixTime(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+func unixTimePtr(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	ts := t.Unix()
+	return &ts
 }
@@ -0,0 +1,107 @@
+package meili
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// itemFacets are the attributes whose value counts are returned with item searches
+var itemFacets = []string{"labels.name", "involved_users.username", "kind", "group_id", "state"}
+
+// TimeRange limits a date attribute. Nil bounds are open
+type TimeRange struct {
+	After  *time.Time
+	Before *time.Time
+}
+
+// ItemFilter restricts item searches. Empty fields don't filter
+type ItemFilter struct {
+	State GitLabItemState
+	// Labels must all be present on an item
+	Labels []string
+	// Users must all be involved in an item
+	Users []string
+	// Kinds and GroupIDs match if any of them matches
+	Kinds    []ItemKind
+	GroupIDs []int
+
+	Created TimeRange
+	Updated TimeRange
+	Closed  TimeRange
+}
+
+// quoteFilterValue quotes a string for use in a Meili filter expression
+func quoteFilterValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
+
+func (r TimeRange) expressions(attribute string) (out []string) {
+	if r.After != nil {
+		out = append(out, fmt.Sprintf("%s >= %d", attribute, r.After.Unix()))
+	}
+	if r.Before != nil {
+		out = append(out, fmt.Sprintf("%s < %d", attribute, r.Before.Unix()))
+	}
+	return out
+}
+
+// Expressions returns Meili filter expressions that must all match
+func (f ItemFilter) Expressions() (out []string) {
+	if f.State != "" {
+		out = append(out, "state = "+quoteFilterValue(string(f.State)))
+	}
+
+	for _, label := range f.Labels {
+		out = append(out, "labels.name = "+quoteFilterValue(label))
+	}
+	for _, user := range f.Users {
+		out = append(out, "involved_users.username = "+quoteFilterValue(user))
+	}
+
+	if len(f.Kinds) > 0 {
+		var kinds = make([]string, 0, len(f.Kinds))
+		for _, kind := range f.Kinds {
+			kinds = append(kinds, quoteFilterValue(string(kind)))
+		}
+		out = append(out, "kind IN ["+strings.Join(kinds, ", ")+"]")
+	}
+	if len(f.GroupIDs) > 0 {
+		out = append(out, "group_id IN ["+joinInts(f.GroupIDs)+"]")
+	}
+
+	out = append(out, f.Created.expressions("created_at_ts")...)
+	out = append(out, f.Updated.expressions("updated_at_ts")...)
+	out = append(out, f.Closed.expressions("closed_at_ts")...)
+
+	return out
+}
+
+// ParseDate parses a date like 2026-09-01 or a full RFC 3339 timestamp
+func ParseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date (YYYY-MM-DD) nor an RFC 3339 timestamp", value)
+	}
+	return t, nil
+}
+
+// ParseItemKind returns the kind with the given name, accepting the short forms used in item IDs
+func ParseItemKind(kind string) (ItemKind, bool) {
+	switch kind {
+	case "issue", "i":
+		return ItemKindIssue, true
+	case "merge_request", "mr":
+		return ItemKindMergeRequest, true
+	case "epic", "e":
+		return ItemKindEpic, true
+	default:
+		return "", false
+	}
+}
@@ -9,7 +9,7 @@ import (
 
 const perPageEntries = 100
 
-func listAllGroupMembers(g *gitlab.Client, groupID interface{}) (users []*gitlab.GroupMember, err error) {
+func listAllGroupMembers(g *gitlab.Client, groupID interface{}, options ...gitlab.RequestOptionFunc) (users []*gitlab.GroupMember, err error) {
 	var page = 1
 
 	for {
@@ -18,7 +18,7 @@ func listAllGroupMembers(g *gitlab.Client, groupID interface{}) (users []*gitlab
 				Page:    page,
 				PerPage: perPageEntries,
 			},
-		})
+		}, options...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list group members: %w", err)
 		}
@@ -34,7 +34,7 @@ func listAllGroupMembers(g *gitlab.Client, groupID interface{}) (users []*gitlab
 	return users, nil
 }
 
-func listAllGroupIssues(client *gitlab.Client, groupID any, updatedAfter *time.Time) ([]*gitlab.Issue, error) {
+func listAllGroupIssues(client *gitlab.Client, groupID any, updatedAfter *time.Time, requestOptions ...gitlab.RequestOptionFunc) ([]*gitlab.Issue, error) {
 	var allIssues []*gitlab.Issue
 
 	options := &gitlab.ListGroupIssuesOptions{
@@ -51,13 +51,14 @@ func listAllGroupIssues(client *gitlab.Client, groupID any, updatedAfter *time.T
 	}
 
 	for {
-		issues, resp, err := client.Issues.ListGroupIssues(groupID, options)
+		issues, resp, err := client.Issues.ListGroupIssues(groupID, options, requestOptions...)
 		if err != nil {
 			return nil, err
 		}
 
 		for _, issue := range issues {
-			if issue.Confidential {
+			// Moved issues stay behind as closed copies, the moved-to issue is indexed instead
+			if issue.Confidential || issue.MovedToID != 0 {
 				continue
 			}
 			allIssues = append(allIssues, issue)
@@ -78,7 +79,7 @@ func listAllGroupIssues(client *gitlab.Client, groupID any, updatedAfter *time.T
 	return allIssues, nil
 }
 
-func listAllGroupMergeRequests(client *gitlab.Client, groupID any, updatedAfter *time.Time) ([]*gitlab.BasicMergeRequest, error) {
+func listAllGroupMergeRequests(client *gitlab.Client, groupID any, updatedAfter *time.Time, requestOptions ...gitlab.RequestOptionFunc) ([]*gitlab.BasicMergeRequest, error) {
 	var allMergeRequests []*gitlab.BasicMergeRequest
 
 	options := &gitlab.ListGroupMergeRequestsOptions{
@@ -95,7 +96,7 @@ func listAllGroupMergeRequests(client *gitlab.Client, groupID any, updatedAfter
 	}
 
 	for {
-		mergeRequests, resp, err := client.MergeRequests.ListGroupMergeRequests(groupID, options)
+		mergeRequests, resp, err := client.MergeRequests.ListGroupMergeRequests(groupID, options, requestOptions...)
 		if err != nil {
 			return nil, err
 		}
@@ -117,7 +118,7 @@ func listAllGroupMergeRequests(client *gitlab.Client, groupID any, updatedAfter
 	return allMergeRequests, nil
 }
 
-func listAllGroupEpics(client *gitlab.Client, groupID any, updatedAfter *time.Time) ([]*gitlab.Epic, error) {
+func listAllGroupEpics(client *gitlab.Client, groupID any, updatedAfter *time.Time, requestOptions ...gitlab.RequestOptionFunc) ([]*gitlab.Epic, error) {
 	var allEpics []*gitlab.Epic
 
 	options := &gitlab.ListGroupEpicsOptions{
@@ -136,7 +137,7 @@ func listAllGroupEpics(client *gitlab.Client, groupID any, updatedAfter *time.Ti
 	}
 
 	for {
-		epics, resp, err := client.Epics.ListGroupEpics(groupID, options)
+		epics, resp, err := client.Epics.ListGroupEpics(groupID, options, requestOptions...)
 		if err != nil {
 			return nil, err
 		}
@@ -157,3 +158,132 @@ func listAllGroupEpics(client *gitlab.Client, groupID any, updatedAfter *time.Ti
 
 	return allEpics, nil
 }
+
+func listAllGroupProjects(client *gitlab.Client, groupID any, requestOptions ...gitlab.RequestOptionFunc) (projects []*gitlab.Project, err error) {
+	options := &gitlab.ListGroupProjectsOptions{
+		ListOptions: gitlab.ListOptions{
+			Page:    1,
+			PerPage: perPageEntries,
+		},
+		IncludeSubGroups: gitlab.Ptr(true),
+	}
+
+	for {
+		page, resp, err := client.Groups.ListGroupProjects(groupID, options, requestOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list group projects: %w", err)
+		}
+
+		projects = append(projects, page...)
+
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+
+		options.Page = resp.NextPage
+	}
+
+	return projects, nil
+}
+
+// listAllProjectMembers lists direct and inherited members of a project
+func listAllProjectMembers(client *gitlab.Client, projectID any, requestOptions ...gitlab.RequestOptionFunc) (members []*gitlab.ProjectMember, err error) {
+	options := &gitlab.ListProjectMembersOptions{
+		ListOptions: gitlab.ListOptions{
+			Page:    1,
+			PerPage: perPageEntries,
+		},
+	}
+
+	for {
+		page, resp, err := client.ProjectMembers.ListAllProjectMembers(projectID, options, requestOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list project members: %w", err)
+		}
+
+		members = append(members, page...)
+
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+
+		options.Page = resp.NextPage
+	}
+
+	return members, nil
+}
+
+func listAllIssuesClosedOnMerge(client *gitlab.Client, projectID any, mergeRequestIID int, options ...gitlab.RequestOptionFunc) (issues []*gitlab.Issue, err error) {
+	opt := &gitlab.GetIssuesClosedOnMergeOptions{
+		Page:    1,
+		PerPage: perPageEntries,
+	}
+
+	for {
+		page, resp, err := client.MergeRequests.GetIssuesClosedOnMerge(projectID, mergeRequestIID, opt, options...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list issues closed on merge: %w", err)
+		}
+
+		issues = append(issues, page...)
+
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+
+		opt.Page = resp.NextPage
+	}
+
+	return issues, nil
+}
+
+// listGroupMilestoneItems lists the issues and merge requests of the group that are in a milestone with the given title
+func listGroupMilestoneItems(client *gitlab.Client, groupID any, milestone string, options ...gitlab.RequestOptionFunc) (issues []*gitlab.Issue, mergeRequests []*gitlab.BasicMergeRequest, err error) {
+	issueOptions := &gitlab.ListGroupIssuesOptions{
+		ListOptions: gitlab.ListOptions{
+			Page:    1,
+			PerPage: perPageEntries,
+		},
+		Milestone: gitlab.Ptr(milestone),
+	}
+
+	for {
+		page, resp, err := client.Issues.ListGroupIssues(groupID, issueOptions, options...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list milestone issues: %w", err)
+		}
+
+		issues = append(issues, page...)
+
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+
+		issueOptions.Page = resp.NextPage
+	}
+
+	mrOptions := &gitlab.ListGroupMergeRequestsOptions{
+		ListOptions: gitlab.ListOptions{
+			Page:    1,
+			PerPage: perPageEntries,
+		},
+		Milestone: gitlab.Ptr(milestone),
+	}
+
+	for {
+		page, resp, err := client.MergeRequests.ListGroupMergeRequests(groupID, mrOptions, options...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list milestone merge requests: %w", err)
+		}
+
+		mergeRequests = append(mergeRequests, page...)
+
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+
+		mrOptions.Page = resp.NextPage
+	}
+
+	return issues, mergeRequests, nil
+}
@@ -0,0 +1,42 @@
+package meili
+
+import (
+	"context"
+	"fmt"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// MilestoneItemIDs returns the IDs of the issues and merge requests the user can see that are in a milestone
+// with the given title. Group and project milestones of all synced groups are included
+func (c *DBClient) MilestoneItemIDs(ctx context.Context, userID int, title string) ([]string, error) {
+	var ids []string
+	for _, group := range c.groups {
+		issues, mergeRequests, err := listGroupMilestoneItems(c.gitlabClient, group.ID, title, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get items of milestone %q in group %q: %w", title, group.Name, err)
+		}
+
+		for _, issue := range issues {
+			ids = append(ids, ItemID(ItemKindIssue, issue.ID))
+		}
+		for _, mr := range mergeRequests {
+			ids = append(ids, ItemID(ItemKindMergeRequest, mr.ID))
+		}
+	}
+
+	// Only keep items in the index, which also drops confidential issues
+	visible, err := c.GetItemsByIDs(ctx, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var out = make([]string, 0, len(visible))
+	for _, id := range ids {
+		if _, ok := visible[id]; ok {
+			out = append(out, id)
+		}
+	}
+
+	return out, nil
+}
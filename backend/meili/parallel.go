@@ -0,0 +1,62 @@
+package meili
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// forEachParallel calls fn for every element with at most limit calls running at the same time. It waits for all
+// started calls and returns their errors combined, in the order of the elements. Once ctx is cancelled no more calls
+// are started and the context's error is added
+func forEachParallel[T any](ctx context.Context, limit int, elements []T, fn func(ctx context.Context, i int, element T) error) error {
+	limit = max(limit, 1)
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, limit)
+		errs = make([]error, len(elements)+1)
+	)
+
+	for i, element := range elements {
+		// Checked first, as select picks randomly if a slot is free as well
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			errs[i] = fn(ctx, i, element)
+		}()
+	}
+
+	wg.Wait()
+
+	errs[len(elements)] = ctx.Err()
+	return errors.Join(errs...)
+}
+
+// groupList returns the synced groups ordered by ID, so work on them is started in the same order every time
+func (c *DBClient) groupList() []*gitlab.Group {
+	var groups = make([]*gitlab.Group, 0, len(c.groups))
+	for _, group := range c.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].ID < groups[j].ID
+	})
+	return groups
+}
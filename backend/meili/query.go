@@ -3,61 +3,129 @@ package meili
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/meilisearch/meilisearch-go"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
 )
 
-func (c *DBClient) SearchUsers(ctx context.Context, query string) (users []User, err error) {
+const (
+	DefaultSearchLimit = 10
+	MaxSearchLimit     = 100
+)
+
+// Page selects a window of search results
+type Page struct {
+	Limit  int64
+	Offset int64
+}
+
+// ParsePage parses the limit and offset query parameters. Empty values use the defaults, limits above MaxSearchLimit are capped
+func ParsePage(limit, offset string) (page Page, err error) {
+	page.Limit = DefaultSearchLimit
+	if limit != "" {
+		page.Limit, err = strconv.ParseInt(limit, 10, 64)
+		if err != nil || page.Limit < 1 {
+			return Page{}, fmt.Errorf("limit %q is not a positive number", limit)
+		}
+		page.Limit = min(page.Limit, MaxSearchLimit)
+	}
+
+	if offset != "" {
+		page.Offset, err = strconv.ParseInt(offset, 10, 64)
+		if err != nil || page.Offset < 0 {
+			return Page{}, fmt.Errorf("offset %q is not a non-negative number", offset)
+		}
+	}
+
+	return page, nil
+}
+
+// SearchResult is a page of hits with Meili's metadata about the whole result set
+type SearchResult[T any] struct {
+	Hits               []T   `json:"hits"`
+	EstimatedTotalHits int64 `json:"estimatedTotalHits"`
+	ProcessingTimeMs   int64 `json:"processingTimeMs"`
+	Limit              int64 `json:"limit"`
+	Offset             int64 `json:"offset"`
+
+	// FacetDistribution counts the values of each facet over all hits, e.g. {"labels.name": {"bug": 12}}
+	FacetDistribution map[string]map[string]int64 `json:"facetDistribution,omitempty"`
+}
+
+func emptyResult[T any](page Page) *SearchResult[T] {
+	return &SearchResult[T]{
+		Hits:   []T{},
+		Limit:  page.Limit,
+		Offset: page.Offset,
+	}
+}
+
+func decodeSearchResult[T any](resp *json.RawMessage) (*SearchResult[T], error) {
+	var r SearchResult[T]
+	if err := json.Unmarshal(*resp, &r); err != nil {
+		return nil, err
+	}
+	if r.Hits == nil {
+		r.Hits = []T{}
+	}
+	return &r, nil
+}
+
+func (c *DBClient) SearchUsers(ctx context.Context, query string, page Page) (*SearchResult[User], error) {
 	index := c.client.Index(USERS_INDEX)
 
 	resp, err := index.SearchRawWithContext(ctx, query, &meilisearch.SearchRequest{
-		Limit:                10,
+		Limit:                page.Limit,
+		Offset:               page.Offset,
 		AttributesToRetrieve: []string{"*"},
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	var r struct {
-		Hits []User `json:"hits"`
-	}
-	if err := json.Unmarshal(*resp, &r); err != nil {
-		return nil, err
-	}
-
-	return r.Hits, nil
+	return decodeSearchResult[User](resp)
 }
 
-func (c *DBClient) SearchItems(ctx context.Context, query string, state GitLabItemState, sort Sort) (items []GitLabItem, err error) {
+// SearchItems searches the items the user with the given GitLab ID can see
+func (c *DBClient) SearchItems(ctx context.Context, userID int, query string, itemFilter ItemFilter, sort Sort, page Page) (*SearchResult[GitLabItem], error) {
 	index := c.client.Index(ITEMS_INDEX)
 
-	var filter []string
-	if state != "" {
-		filter = append(filter, "state="+string(state))
+	visibility, ok := c.VisibilityFilter(userID)
+	if !ok {
+		return emptyResult[GitLabItem](page), nil
 	}
+
+	var filter = append([]string{visibility}, itemFilter.Expressions()...)
 	var sortSlice []string
 	if sf := sort.ToFilter(); sf != "" {
 		sortSlice = append(sortSlice, sf)
 	}
 
 	resp, err := index.SearchRawWithContext(ctx, query, &meilisearch.SearchRequest{
-		Limit:                10,
+		Limit:                page.Limit,
+		Offset:               page.Offset,
 		AttributesToRetrieve: []string{"*"},
 		Filter:               filter,
 		Sort:                 sortSlice,
+		Facets:               itemFacets,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	var r struct {
-		Hits []GitLabItem `json:"hits"`
-	}
-	if err := json.Unmarshal(*resp, &r); err != nil {
-		return nil, err
-	}
+	return decodeSearchResult[GitLabItem](resp)
+}
 
-	return r.Hits, nil
+var ErrUserNotFound = errors.New("user not found")
+
+func isMeiliNotFound(err error) bool {
+	var meiliErr *meilisearch.Error
+	return errors.As(err, &meiliErr) && meiliErr.StatusCode == http.StatusNotFound
 }
 
 func (c *DBClient) GetUserByID(ctx context.Context, id string) (User, error) {
@@ -68,8 +136,96 @@ func (c *DBClient) GetUserByID(ctx context.Context, id string) (User, error) {
 		Fields: []string{"*"},
 	}, &user)
 	if err != nil {
+		if isMeiliNotFound(err) {
+			return User{}, ErrUserNotFound
+		}
 		return User{}, err
 	}
 
 	return user, nil
 }
+
+// FetchGitLabUser returns the current state of a user from GitLab instead of the index
+func (c *DBClient) FetchGitLabUser(ctx context.Context, id int) (User, error) {
+	user, resp, err := c.gitlabClient.Users.GetUser(id, gitlab.GetUsersOptions{}, gitlab.WithContext(ctx))
+	if err != nil {
+		if isNotFound(resp) {
+			return User{}, ErrUserNotFound
+		}
+		return User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	return FromGitLabUser(user), nil
+}
+
+// itemBatchSize is the maximum number of IDs requested from Meili at once
+const itemBatchSize = 200
+
+// GetItemsByIDs returns the items with the given document IDs that the user can see.
+// IDs that are not in the index or not visible are missing from the result
+func (c *DBClient) GetItemsByIDs(ctx context.Context, userID int, ids []string) (map[string]GitLabItem, error) {
+	index := c.client.Index(ITEMS_INDEX)
+
+	var items = make(map[string]GitLabItem, len(ids))
+
+	visibility, ok := c.VisibilityFilter(userID)
+	if !ok {
+		return items, nil
+	}
+
+	for start := 0; start < len(ids); start += itemBatchSize {
+		batch := ids[start:min(start+itemBatchSize, len(ids))]
+
+		var quoted = make([]string, 0, len(batch))
+		for _, id := range batch {
+			quoted = append(quoted, quoteFilterValue(id))
+		}
+
+		var resp meilisearch.DocumentsResult
+		err := index.GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{
+			Limit:  int64(len(batch)),
+			Filter: "(" + visibility + ") AND id IN [" + strings.Join(quoted, ", ") + "]",
+		}, &resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get items: %w", err)
+		}
+
+		var batchItems []GitLabItem
+		if err := decodeDocuments(resp.Results, &batchItems); err != nil {
+			return nil, err
+		}
+
+		for _, item := range batchItems {
+			items[item.ID] = item
+		}
+	}
+
+	return items, nil
+}
+
+// GetItemByID returns a single item if the user can see it
+func (c *DBClient) GetItemByID(ctx context.Context, userID int, id string) (GitLabItem, error) {
+	items, err := c.GetItemsByIDs(ctx, userID, []string{id})
+	if err != nil {
+		return GitLabItem{}, err
+	}
+
+	item, ok := items[id]
+	if !ok {
+		return GitLabItem{}, ErrItemNotFound
+	}
+	return item, nil
+}
+
+// decodeDocuments converts the generic documents returned by Meili into typed values
+func decodeDocuments(documents []map[string]interface{}, out any) error {
+	data, err := json.Marshal(documents)
+	if err != nil {
+		return fmt.Errorf("failed to encode documents: %w", err)
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode documents: %w", err)
+	}
+
+	return nil
+}
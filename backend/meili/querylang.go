@@ -0,0 +1,275 @@
+package meili
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// QueryError describes a qualifier in a search query that could not be understood
+type QueryError struct {
+	// Token is the qualifier as it was typed, e.g. "is:opne"
+	Token string `json:"token"`
+	// Offset is the byte offset of the token in the query
+	Offset  int    `json:"offset"`
+	Message string `json:"message"`
+}
+
+func (e QueryError) Error() string {
+	return fmt.Sprintf("%s (at %d): %s", e.Token, e.Offset, e.Message)
+}
+
+// QueryErrors is returned by ParseQuery with all invalid qualifiers of a query
+type QueryErrors []QueryError
+
+func (e QueryErrors) Error() string {
+	var msgs = make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return "invalid search query: " + strings.Join(msgs, "; ")
+}
+
+// ParsedQuery is a search query split into free text and filters
+type ParsedQuery struct {
+	// Text is what remains for the full-text search
+	Text   string
+	Filter ItemFilter
+	// HasQualifiers is true if the query contained at least one qualifier
+	HasQualifiers bool
+}
+
+type queryToken struct {
+	text   string
+	offset int
+}
+
+// tokenizeQuery splits a query at whitespace. Double quotes group words, also within a qualifier value like label:"needs review"
+func tokenizeQuery(query string) (tokens []queryToken) {
+	var current strings.Builder
+	var start = -1
+	var quoted bool
+
+	for i, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if start >= 0 {
+				tokens = append(tokens, queryToken{text: current.String(), offset: start})
+				current.Reset()
+				start = -1
+			}
+			continue
+		}
+
+		if start < 0 {
+			start = i
+		}
+		current.WriteRune(r)
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, queryToken{text: current.String(), offset: start})
+	}
+
+	return tokens
+}
+
+// unquote removes surrounding double quotes from a qualifier value
+func unquote(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// ParseQuery understands GitLab-style qualifiers in a search query, e.g.
+//
+//	label:bug assignee:@alice is:open kind:mr updated:>2026-09-01 login crash
+//
+// Supported qualifiers are label, assignee/author/user (all match involved users), is (state), kind, group
+// and created/updated/closed with an optional >, >=, <, <= or = operator or a from..to range.
+// Words with unknown qualifiers are kept as free text
+func ParseQuery(query string) (parsed ParsedQuery, err error) {
+	var text []string
+	var errs QueryErrors
+
+	for _, token := range tokenizeQuery(query) {
+		key, value, found := strings.Cut(token.text, ":")
+		if !found || strings.HasPrefix(key, `"`) {
+			text = append(text, token.text)
+			continue
+		}
+
+		var fail = func(format string, args ...any) {
+			errs = append(errs, QueryError{
+				Token:   token.text,
+				Offset:  token.offset,
+				Message: fmt.Sprintf(format, args...),
+			})
+		}
+
+		value = unquote(value)
+		key = strings.ToLower(key)
+
+		switch key {
+		case "label", "assignee", "author", "user", "is", "kind", "group", "created", "updated", "closed":
+			parsed.HasQualifiers = true
+			if value == "" {
+				fail("missing value")
+				continue
+			}
+		default:
+			text = append(text, token.text)
+			continue
+		}
+
+		switch key {
+		case "label":
+			parsed.Filter.Labels = append(parsed.Filter.Labels, strings.TrimPrefix(value, "~"))
+		case "assignee", "author", "user":
+			// The index doesn't record roles, so all of these match any involved user
+			parsed.Filter.Users = append(parsed.Filter.Users, strings.TrimPrefix(value, "@"))
+		case "is":
+			state := ParseItemState(value)
+			if value == "open" {
+				state = GitLabItemStateOpened
+			}
+			if state == "" {
+				fail("unknown state %q, expected open, closed, merged or locked", value)
+				continue
+			}
+			parsed.Filter.State = state
+		case "kind":
+			kind, ok := ParseItemKind(value)
+			if !ok {
+				fail("unknown kind %q, expected issue, mr or epic", value)
+				continue
+			}
+			parsed.Filter.Kinds = append(parsed.Filter.Kinds, kind)
+		case "group":
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				fail("group must be a numeric ID")
+				continue
+			}
+			parsed.Filter.GroupIDs = append(parsed.Filter.GroupIDs, id)
+		case "created", "updated", "closed":
+			r, err := parseDateQualifier(value)
+			if err != nil {
+				fail("%v", err)
+				continue
+			}
+			switch key {
+			case "created":
+				parsed.Filter.Created = r
+			case "updated":
+				parsed.Filter.Updated = r
+			case "closed":
+				parsed.Filter.Closed = r
+			}
+		}
+	}
+
+	parsed.Text = strings.Join(text, " ")
+
+	if len(errs) > 0 {
+		return parsed, errs
+	}
+	return parsed, nil
+}
+
+// parseDateBound parses a date and returns it with the length of the period it stands for,
+// so that e.g. <=2026-09-01 includes the whole day
+func parseDateBound(value string) (t time.Time, period time.Duration, err error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, 24 * time.Hour, nil
+	}
+
+	t, err = ParseDate(value)
+	return t, time.Second, err
+}
+
+func parseDateQualifier(value string) (r TimeRange, err error) {
+	if from, to, ok := strings.Cut(value, ".."); ok {
+		if from != "" {
+			start, _, err := parseDateBound(from)
+			if err != nil {
+				return r, err
+			}
+			r.After = &start
+		}
+		if to != "" {
+			end, period, err := parseDateBound(to)
+			if err != nil {
+				return r, err
+			}
+			end = end.Add(period)
+			r.Before = &end
+		}
+		if r.After == nil && r.Before == nil {
+			return r, fmt.Errorf("range needs at least one date")
+		}
+		return r, nil
+	}
+
+	var op string
+	for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+		if rest, ok := strings.CutPrefix(value, candidate); ok {
+			op, value = candidate, rest
+			break
+		}
+	}
+
+	t, period, err := parseDateBound(value)
+	if err != nil {
+		return r, err
+	}
+
+	// TimeRange.After is inclusive and TimeRange.Before is exclusive
+	switch op {
+	case ">":
+		after := t.Add(period)
+		r.After = &after
+	case ">=":
+		r.After = &t
+	case "<":
+		r.Before = &t
+	case "<=":
+		before := t.Add(period)
+		r.Before = &before
+	default:
+		before := t.Add(period)
+		r.After, r.Before = &t, &before
+	}
+
+	return r, nil
+}
+
+// Merge adds the restrictions of other to the filter. Single values like the state and set time bounds of other win
+func (f *ItemFilter) Merge(other ItemFilter) {
+	if other.State != "" {
+		f.State = other.State
+	}
+
+	f.Labels = append(f.Labels, other.Labels...)
+	f.Users = append(f.Users, other.Users...)
+	f.Kinds = append(f.Kinds, other.Kinds...)
+	f.GroupIDs = append(f.GroupIDs, other.GroupIDs...)
+
+	f.Created.merge(other.Created)
+	f.Updated.merge(other.Updated)
+	f.Closed.merge(other.Closed)
+}
+
+func (r *TimeRange) merge(other TimeRange) {
+	if other.After != nil {
+		r.After = other.After
+	}
+	if other.Before != nil {
+		r.Before = other.Before
+	}
+}
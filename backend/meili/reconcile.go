@@ -0,0 +1,129 @@
+package meili
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/meilisearch/meilisearch-go"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// reconcilePageSize is how many document IDs are fetched from the index per request
+const reconcilePageSize = 1000
+
+// indexedItemIDs returns the IDs of all indexed items of the group and kind
+func (c *DBClient) indexedItemIDs(ctx context.Context, group *gitlab.Group, kind ItemKind) (map[string]struct{}, error) {
+	index := c.client.Index(ITEMS_INDEX)
+
+	var ids = make(map[string]struct{})
+	for offset := int64(0); ; offset += reconcilePageSize {
+		var resp meilisearch.DocumentsResult
+		err := index.GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{
+			Offset: offset,
+			Limit:  reconcilePageSize,
+			Fields: []string{"id"},
+			Filter: fmt.Sprintf("group_id = %d AND kind = %s", group.ID, kind),
+		}, &resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list indexed %s items: %w", kind, err)
+		}
+
+		for _, doc := range resp.Results {
+			if id, ok := doc["id"].(string); ok {
+				ids[id] = struct{}{}
+			}
+		}
+
+		if len(resp.Results) < reconcilePageSize {
+			return ids, nil
+		}
+	}
+}
+
+// gitlabItemIDs returns the IDs of all items of the group and kind that should be in the index
+func (c *DBClient) gitlabItemIDs(ctx context.Context, group *gitlab.Group, kind ItemKind) (map[string]struct{}, error) {
+	var ids = make(map[string]struct{})
+
+	switch kind {
+	case ItemKindIssue:
+		issues, err := listAllGroupIssues(c.gitlabClient, group.ID, nil, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			ids[ItemID(kind, issue.ID)] = struct{}{}
+		}
+	case ItemKindMergeRequest:
+		mergeRequests, err := listAllGroupMergeRequests(c.gitlabClient, group.ID, nil, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, mr := range mergeRequests {
+			ids[ItemID(kind, mr.ID)] = struct{}{}
+		}
+	case ItemKindEpic:
+		epics, err := listAllGroupEpics(c.gitlabClient, group.ID, nil, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, epic := range epics {
+			ids[ItemID(kind, epic.ID)] = struct{}{}
+		}
+	default:
+		return nil, fmt.Errorf("unknown item kind %q", kind)
+	}
+
+	return ids, nil
+}
+
+// reconcileGroupItems removes items from the index that GitLab no longer reports for the group, e.g. because they
+// were deleted, moved to another project or made confidential. The incremental sync never notices these
+func (c *DBClient) reconcileGroupItems(ctx context.Context, group *gitlab.Group) (count int, err error) {
+	kinds := []ItemKind{ItemKindIssue, ItemKindMergeRequest, ItemKindEpic}
+	var counts = make([]int, len(kinds))
+
+	err = forEachParallel(ctx, len(kinds), kinds, func(ctx context.Context, i int, kind ItemKind) (err error) {
+		counts[i], err = c.reconcileGroupKind(ctx, group, kind)
+		if err != nil {
+			return fmt.Errorf("failed to reconcile %s items: %w", kind, err)
+		}
+		return nil
+	})
+
+	for _, removed := range counts {
+		count += removed
+	}
+
+	return count, err
+}
+
+func (c *DBClient) reconcileGroupKind(ctx context.Context, group *gitlab.Group, kind ItemKind) (count int, err error) {
+	// The index must be listed before GitLab, so items indexed in between are never considered stale
+	indexed, err := c.indexedItemIDs(ctx, group, kind)
+	if err != nil {
+		return 0, err
+	}
+
+	// If GitLab can't be listed completely, nothing is deleted
+	current, err := c.gitlabItemIDs(ctx, group, kind)
+	if err != nil {
+		return 0, err
+	}
+
+	var stale []string
+	for id := range indexed {
+		if _, ok := current[id]; !ok {
+			stale = append(stale, id)
+		}
+	}
+
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	if err := c.deleteItems(ctx, stale); err != nil {
+		return 0, err
+	}
+
+	return len(stale), nil
+}
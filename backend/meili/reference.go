@@ -0,0 +1,306 @@
+package meili
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/meilisearch/meilisearch-go"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+var (
+	ErrInvalidReference = errors.New("not a GitLab reference or URL")
+	ErrItemNotFound     = errors.New("item not found")
+)
+
+// Reference points to a single issue, merge request or epic
+type Reference struct {
+	Kind ItemKind
+	// Path is the full path of the project for issues and merge requests, and of the group for epics.
+	// It is empty for epics referenced as &7
+	Path string
+	IID  int
+}
+
+// Slug returns the reference in the format stored in GitLabItem.Slug
+func (r Reference) Slug() string {
+	switch r.Kind {
+	case ItemKindIssue:
+		return r.Path + "#" + strconv.Itoa(r.IID)
+	case ItemKindMergeRequest:
+		return r.Path + "!" + strconv.Itoa(r.IID)
+	default:
+		return "&" + strconv.Itoa(r.IID)
+	}
+}
+
+var (
+	textReferencePattern = regexp.MustCompile(`^([\w.\-/]*)([#!&])(\d+)$`)
+	urlPathPattern       = regexp.MustCompile(`^/(?:groups/)?(.+?)/-/(issues|work_items|merge_requests|epics)/(\d+)(?:/.*)?$`)
+)
+
+// ParseReference recognizes references like group/project#12, group/project!34, &7 or group&7,
+// and web URLs of issues, merge requests and epics on the given GitLab instance
+func ParseReference(ref string, instanceURL string) (Reference, bool) {
+	ref = strings.TrimSpace(ref)
+
+	if m := textReferencePattern.FindStringSubmatch(ref); m != nil {
+		iid, err := strconv.Atoi(m[3])
+		if err != nil || iid <= 0 {
+			return Reference{}, false
+		}
+
+		switch m[2] {
+		case "#":
+			if m[1] == "" {
+				// #12 without a project is ambiguous
+				return Reference{}, false
+			}
+			return Reference{Kind: ItemKindIssue, Path: m[1], IID: iid}, true
+		case "!":
+			if m[1] == "" {
+				return Reference{}, false
+			}
+			return Reference{Kind: ItemKindMergeRequest, Path: m[1], IID: iid}, true
+		default:
+			return Reference{Kind: ItemKindEpic, Path: m[1], IID: iid}, true
+		}
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" {
+		return Reference{}, false
+	}
+
+	instance, err := url.Parse(instanceURL)
+	if err != nil || !strings.EqualFold(u.Host, instance.Host) {
+		return Reference{}, false
+	}
+
+	// Instances can be served from a relative URL root like https://example.com/gitlab
+	path, ok := strings.CutPrefix(u.Path, strings.TrimSuffix(instance.Path, "/"))
+	if !ok {
+		return Reference{}, false
+	}
+
+	m := urlPathPattern.FindStringSubmatch(path)
+	if m == nil {
+		return Reference{}, false
+	}
+
+	iid, err := strconv.Atoi(m[3])
+	if err != nil || iid <= 0 {
+		return Reference{}, false
+	}
+
+	var kind ItemKind
+	switch m[2] {
+	case "issues", "work_items":
+		kind = ItemKindIssue
+	case "merge_requests":
+		kind = ItemKindMergeRequest
+	default:
+		kind = ItemKindEpic
+	}
+
+	return Reference{Kind: kind, Path: m[1], IID: iid}, true
+}
+
+// findItems returns up to limit items matching all filters
+func (c *DBClient) findItems(ctx context.Context, filter []string, limit int64) ([]GitLabItem, error) {
+	index := c.client.Index(ITEMS_INDEX)
+
+	resp, err := index.SearchRawWithContext(ctx, "", &meilisearch.SearchRequest{
+		Limit:                limit,
+		AttributesToRetrieve: []string{"*"},
+		Filter:               filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := decodeSearchResult[GitLabItem](resp)
+	if err != nil {
+		return nil, err
+	}
+	return result.Hits, nil
+}
+
+// findItem returns the first visible item matching all filters, or nil
+func (c *DBClient) findItem(ctx context.Context, filter []string) (*GitLabItem, error) {
+	items, err := c.findItems(ctx, filter, 1)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+// ResolveReference returns the item a reference or web URL points to, if the user can see it.
+// Items missing from the index are fetched from GitLab and indexed if they belong to a synced group
+func (c *DBClient) ResolveReference(ctx context.Context, userID int, ref string) (*GitLabItem, error) {
+	reference, ok := ParseReference(ref, c.gitlabConfig.InstanceURL)
+	if !ok {
+		return nil, ErrInvalidReference
+	}
+
+	visibility, ok := c.VisibilityFilter(userID)
+	if !ok {
+		return nil, ErrItemNotFound
+	}
+
+	if reference.Kind == ItemKindEpic {
+		if reference.Path != "" {
+			return c.resolveEpic(ctx, userID, reference)
+		}
+		return c.resolveGroupLessEpic(ctx, visibility, reference)
+	}
+
+	// Looked up without the visibility filter, so hidden items aren't fetched from GitLab again and again
+	lookup := []string{"slug = " + quoteFilterValue(reference.Slug())}
+
+	item, err := c.findItem(ctx, lookup)
+	if err != nil {
+		return nil, err
+	}
+	if item != nil {
+		return c.visibleItem(userID, item)
+	}
+
+	// Not indexed yet, e.g. because it was created after the last sync
+	if err := c.fetchReference(ctx, reference); err != nil {
+		return nil, err
+	}
+
+	item, err = c.findItem(ctx, lookup)
+	if err != nil {
+		return nil, err
+	}
+	return c.visibleItem(userID, item)
+}
+
+// visibleItem returns ErrItemNotFound for missing items and items the user can't see
+func (c *DBClient) visibleItem(userID int, item *GitLabItem) (*GitLabItem, error) {
+	if item == nil || !c.CanSee(userID, *item) {
+		return nil, ErrItemNotFound
+	}
+	return item, nil
+}
+
+// fetchReference fetches an issue or merge request from GitLab and indexes it
+func (c *DBClient) fetchReference(ctx context.Context, reference Reference) error {
+	group := c.groupForProject(reference.Path)
+	if group == nil {
+		return ErrItemNotFound
+	}
+
+	var item GitLabItem
+	switch reference.Kind {
+	case ItemKindIssue:
+		issue, resp, err := c.gitlabClient.Issues.GetIssue(reference.Path, reference.IID, gitlab.WithContext(ctx))
+		if err != nil {
+			if isNotFound(resp) {
+				return ErrItemNotFound
+			}
+			return fmt.Errorf("failed to get issue: %w", err)
+		}
+		if issue.Confidential || issue.MovedToID != 0 {
+			return ErrItemNotFound
+		}
+		item = FromGitLabIssue(issue, group.ID)
+	case ItemKindMergeRequest:
+		mr, resp, err := c.gitlabClient.MergeRequests.GetMergeRequest(reference.Path, reference.IID, nil, gitlab.WithContext(ctx))
+		if err != nil {
+			if isNotFound(resp) {
+				return ErrItemNotFound
+			}
+			return fmt.Errorf("failed to get merge request: %w", err)
+		}
+		item = FromGitLabMergeRequest(&mr.BasicMergeRequest, group.ID)
+	default:
+		return ErrItemNotFound
+	}
+
+	return c.upsertItemsWithRelations(ctx, []GitLabItem{item})
+}
+
+// resolveGroupLessEpic looks up an epic referenced as &7. Every group numbers its epics on its own,
+// so it must be the only visible epic with that IID. It can't be fetched from GitLab without a group
+func (c *DBClient) resolveGroupLessEpic(ctx context.Context, visibility string, reference Reference) (*GitLabItem, error) {
+	items, err := c.findItems(ctx, []string{visibility, fmt.Sprintf("kind = %s", ItemKindEpic), fmt.Sprintf("iid = %d", reference.IID)}, 2)
+	switch {
+	case err != nil:
+		return nil, err
+	case len(items) == 0:
+		return nil, ErrItemNotFound
+	case len(items) > 1:
+		return nil, fmt.Errorf("%w: %s matches epics of several groups, add the group path like group%s", ErrInvalidReference, reference.Slug(), reference.Slug())
+	}
+	return &items[0], nil
+}
+
+// maxEpicCandidates is how many indexed epics with the same IID are compared with a reference before asking GitLab
+const maxEpicCandidates = 100
+
+// resolveEpic looks up an epic of a specific group. Epics are only stored as &7, so they are told apart by their
+// web URL. Epics missing from the index are fetched from GitLab
+func (c *DBClient) resolveEpic(ctx context.Context, userID int, reference Reference) (*GitLabItem, error) {
+	var group *gitlab.Group
+	for _, g := range c.groups {
+		if reference.Path == g.FullPath || strings.HasPrefix(reference.Path, g.FullPath+"/") {
+			group = g
+			break
+		}
+	}
+	if group == nil {
+		return nil, ErrItemNotFound
+	}
+
+	// Epics of subgroups are stored with the synced group they were found in. Visibility is checked
+	// after the match, so epics the user can't see aren't fetched from GitLab
+	candidates, err := c.findItems(ctx, []string{
+		fmt.Sprintf("kind = %s", ItemKindEpic),
+		fmt.Sprintf("iid = %d", reference.IID),
+		fmt.Sprintf("group_id = %d", group.ID),
+	}, maxEpicCandidates)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range candidates {
+		if r, ok := ParseReference(candidate.WebURL, c.gitlabConfig.InstanceURL); ok && strings.EqualFold(r.Path, reference.Path) {
+			return c.visibleItem(userID, &candidate)
+		}
+	}
+
+	epic, resp, err := c.gitlabClient.Epics.GetEpic(reference.Path, reference.IID, gitlab.WithContext(ctx))
+	if err != nil {
+		if isNotFound(resp) {
+			return nil, ErrItemNotFound
+		}
+		return nil, fmt.Errorf("failed to get epic: %w", err)
+	}
+
+	lookup := []string{"id = " + quoteFilterValue(ItemID(ItemKindEpic, epic.ID))}
+
+	item, err := c.findItem(ctx, lookup)
+	if err != nil {
+		return nil, err
+	}
+	if item != nil {
+		return c.visibleItem(userID, item)
+	}
+
+	if err := c.upsertItems([]GitLabItem{FromGitLabEpic(epic, group.ID)}); err != nil {
+		return nil, err
+	}
+
+	item, err = c.findItem(ctx, lookup)
+	if err != nil {
+		return nil, err
+	}
+	return c.visibleItem(userID, item)
+}
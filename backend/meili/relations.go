@@ -0,0 +1,299 @@
+package meili
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/meilisearch/meilisearch-go"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// RelationType describes how an item relates to another item, seen from the item the relation is stored on
+type RelationType string
+
+const (
+	RelationRelatesTo RelationType = "relates_to"
+	RelationBlocks    RelationType = "blocks"
+	RelationBlockedBy RelationType = "is_blocked_by"
+	// RelationParent points from an issue to its epic and from an epic to its parent epic
+	RelationParent RelationType = "parent"
+	RelationChild  RelationType = "child"
+	// RelationCloses points from a merge request to the issues it closes when merged
+	RelationCloses   RelationType = "closes"
+	RelationClosedBy RelationType = "closed_by"
+)
+
+func (t RelationType) Valid() bool {
+	switch t {
+	case RelationRelatesTo, RelationBlocks, RelationBlockedBy, RelationParent, RelationChild, RelationCloses, RelationClosedBy:
+		return true
+	default:
+		return false
+	}
+}
+
+// Inverse returns the type of the same relation seen from the other item
+func (t RelationType) Inverse() RelationType {
+	switch t {
+	case RelationBlocks:
+		return RelationBlockedBy
+	case RelationBlockedBy:
+		return RelationBlocks
+	case RelationParent:
+		return RelationChild
+	case RelationChild:
+		return RelationParent
+	case RelationCloses:
+		return RelationClosedBy
+	case RelationClosedBy:
+		return RelationCloses
+	default:
+		return t
+	}
+}
+
+// Relation links an item to another item by its document ID.
+// Only one side of each relation is stored, the other side is found by filtering on relations.item_id
+type Relation struct {
+	Type   RelationType `json:"type"`
+	ItemID string       `json:"item_id"`
+}
+
+func sortRelations(relations []Relation) {
+	sort.Slice(relations, func(i, j int) bool {
+		if relations[i].Type != relations[j].Type {
+			return relations[i].Type < relations[j].Type
+		}
+		return relations[i].ItemID < relations[j].ItemID
+	})
+}
+
+// addRelations fetches the relations of the items that GitLab only returns from separate endpoints:
+// issue links of issues and the issues merge requests close. Epic relations are part of the converted items.
+// The items whose relations couldn't be fetched are marked in failed, the others still get theirs
+func (c *DBClient) addRelations(ctx context.Context, items []GitLabItem) (failed []bool, err error) {
+	failed = make([]bool, len(items))
+	for i := range failed {
+		failed[i] = true
+	}
+
+	err = forEachParallel(ctx, c.gitlabConfig.SyncConcurrency, items, func(ctx context.Context, i int, _ GitLabItem) error {
+		item := &items[i]
+
+		var err error
+		switch item.Kind {
+		case ItemKindIssue:
+			err = c.addIssueLinks(ctx, item)
+		case ItemKindMergeRequest:
+			err = c.addClosedIssues(ctx, item)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get relations of %s: %w", item.Slug, err)
+		}
+
+		sortRelations(item.Relations)
+		failed[i] = false
+		return nil
+	})
+	return failed, err
+}
+
+// keepIndexedRelations gives the failed items the relations from separate endpoints they have in the index,
+// so indexing them doesn't drop those
+func (c *DBClient) keepIndexedRelations(ctx context.Context, items []GitLabItem, failed []bool) error {
+	var ids []string
+	for i, item := range items {
+		if failed[i] {
+			ids = append(ids, quoteFilterValue(item.ID))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	indexed, err := fetchDocuments(ctx, c.client.Index(ITEMS_INDEX), ids, func(item GitLabItem) string { return item.ID })
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		existing, ok := indexed[items[i].ID]
+		if !failed[i] || !ok {
+			continue
+		}
+		for _, relation := range existing.Relations {
+			// Parents and children are part of the item itself, so they are already up to date
+			if relation.Type != RelationParent && relation.Type != RelationChild {
+				items[i].Relations = append(items[i].Relations, relation)
+			}
+		}
+		sortRelations(items[i].Relations)
+	}
+
+	return nil
+}
+
+// upsertItemsWithRelations fetches the relations of freshly converted items before indexing them
+func (c *DBClient) upsertItemsWithRelations(ctx context.Context, items []GitLabItem) error {
+	if _, err := c.addRelations(ctx, items); err != nil {
+		return err
+	}
+	return c.upsertItems(items)
+}
+
+// isForbiddenOrNotFound reports whether an optional endpoint isn't available, e.g. because of the GitLab tier
+func isForbiddenOrNotFound(resp *gitlab.Response) bool {
+	return resp != nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound)
+}
+
+func (c *DBClient) addIssueLinks(ctx context.Context, item *GitLabItem) error {
+	links, resp, err := c.gitlabClient.IssueLinks.ListIssueRelations(item.ProjectID, item.IID, gitlab.WithContext(ctx))
+	if err != nil {
+		if isForbiddenOrNotFound(resp) {
+			return nil
+		}
+		return err
+	}
+
+	for _, link := range links {
+		item.Relations = append(item.Relations, Relation{
+			Type:   RelationType(link.LinkType),
+			ItemID: ItemID(ItemKindIssue, link.ID),
+		})
+	}
+
+	return nil
+}
+
+func (c *DBClient) addClosedIssues(ctx context.Context, item *GitLabItem) error {
+	issues, err := listAllIssuesClosedOnMerge(c.gitlabClient, item.ProjectID, item.IID, gitlab.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		item.Relations = append(item.Relations, Relation{
+			Type:   RelationCloses,
+			ItemID: ItemID(ItemKindIssue, issue.ID),
+		})
+	}
+
+	return nil
+}
+
+// ItemRelation is a relation of an item together with the related item
+type ItemRelation struct {
+	Type   RelationType `json:"type"`
+	ItemID string       `json:"item_id"`
+	Item   GitLabItem   `json:"item"`
+}
+
+// GetItemRelations returns the relations of an item the user can see in both directions.
+// Relations to items that are not indexed or not visible to the user are left out
+func (c *DBClient) GetItemRelations(ctx context.Context, userID int, id string) ([]ItemRelation, error) {
+	item, err := c.GetItemByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var relations = append([]Relation{}, item.Relations...)
+
+	incoming, err := c.findRelatedItems(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range incoming {
+		for _, rel := range other.Relations {
+			if rel.ItemID == id {
+				relations = append(relations, Relation{Type: rel.Type.Inverse(), ItemID: other.ID})
+			}
+		}
+	}
+
+	// GitLab stores issue links on both issues, so the same relation can show up from both sides
+	sortRelations(relations)
+	relations = deduplicateRelations(relations)
+
+	var ids = make([]string, 0, len(relations))
+	for _, rel := range relations {
+		ids = append(ids, rel.ItemID)
+	}
+
+	items, err := c.GetItemsByIDs(ctx, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var out = make([]ItemRelation, 0, len(relations))
+	for _, rel := range relations {
+		related, ok := items[rel.ItemID]
+		if !ok {
+			continue
+		}
+		out = append(out, ItemRelation{
+			Type:   rel.Type,
+			ItemID: rel.ItemID,
+			Item:   related,
+		})
+	}
+
+	return out, nil
+}
+
+// findRelatedItems returns all indexed items that store a relation to the given item
+func (c *DBClient) findRelatedItems(ctx context.Context, id string) ([]GitLabItem, error) {
+	index := c.client.Index(ITEMS_INDEX)
+
+	var items []GitLabItem
+	for offset := int64(0); ; offset += itemBatchSize {
+		var resp meilisearch.DocumentsResult
+		err := index.GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{
+			Offset: offset,
+			Limit:  itemBatchSize,
+			Fields: []string{"id", "relations"},
+			Filter: "relations.item_id = " + quoteFilterValue(id),
+		}, &resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get related items: %w", err)
+		}
+
+		var batch []GitLabItem
+		if err := decodeDocuments(resp.Results, &batch); err != nil {
+			return nil, err
+		}
+		items = append(items, batch...)
+
+		if offset+itemBatchSize >= resp.Total {
+			return items, nil
+		}
+	}
+}
+
+func deduplicateRelations(relations []Relation) []Relation {
+	var out []Relation
+	for i, rel := range relations {
+		if i > 0 && rel == relations[i-1] {
+			continue
+		}
+		out = append(out, rel)
+	}
+	return out
+}
+
+// epicRelations returns the relations of an epic that are part of the epic itself
+func epicRelations(epic *gitlab.Epic) []Relation {
+	if epic.ParentID == 0 {
+		return nil
+	}
+	return []Relation{{Type: RelationParent, ItemID: ItemID(ItemKindEpic, epic.ParentID)}}
+}
+
+// issueRelations returns the relations of an issue that are part of the issue itself
+func issueRelations(issue *gitlab.Issue) []Relation {
+	if issue.Epic == nil || issue.Epic.ID == 0 {
+		return nil
+	}
+	return []Relation{{Type: RelationParent, ItemID: ItemID(ItemKindEpic, issue.Epic.ID)}}
+}
@@ -0,0 +1,206 @@
+package meili
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/meilisearch/meilisearch-go"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// ErrUnsupportedEvent is returned for webhook events that don't affect the index
+var ErrUnsupportedEvent = errors.New("unsupported webhook event")
+
+// workItemEvent contains the fields needed to tell epics apart from issues, as GitLab sends
+// epic (work item) changes as issue events
+type workItemEvent struct {
+	ObjectKind       string `json:"object_kind"`
+	ObjectAttributes struct {
+		Type string `json:"type"`
+	} `json:"object_attributes"`
+}
+
+// HandleWebhook updates the index from a GitLab webhook event. The changed item or user is fetched from
+// GitLab and converted the same way the background sync does, so both paths produce identical documents
+func (c *DBClient) HandleWebhook(ctx context.Context, eventType gitlab.EventType, payload []byte) error {
+	if eventType == gitlab.EventTypeIssue || eventType == gitlab.EventConfidentialIssue {
+		var wi workItemEvent
+		if err := json.Unmarshal(payload, &wi); err != nil {
+			return fmt.Errorf("failed to parse event: %w", err)
+		}
+		if strings.EqualFold(wi.ObjectAttributes.Type, "epic") {
+			// Syncing the epics takes longer than GitLab waits for a webhook response
+			c.requestEpicSync()
+			return nil
+		}
+	}
+
+	event, err := gitlab.ParseWebhook(eventType, payload)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedEvent, err)
+	}
+
+	switch event := event.(type) {
+	case *gitlab.IssueEvent:
+		group := c.groupForProject(event.Project.PathWithNamespace)
+		if group == nil {
+			return nil
+		}
+		return c.refreshIssue(ctx, group, event.Project.ID, event.ObjectAttributes.IID, event.ObjectAttributes.ID)
+	case *gitlab.MergeEvent:
+		group := c.groupForProject(event.Project.PathWithNamespace)
+		if group == nil {
+			return nil
+		}
+		return c.refreshMergeRequest(ctx, group, event.Project.ID, event.ObjectAttributes.IID, event.ObjectAttributes.ID)
+	case *gitlab.MemberEvent:
+		group, ok := c.groups[event.GroupID]
+		if !ok {
+			return nil
+		}
+		return c.refreshMember(ctx, group, event.UserID)
+	default:
+		return ErrUnsupportedEvent
+	}
+}
+
+// groupForProject returns the configured group the project belongs to, or nil if it isn't synced
+func (c *DBClient) groupForProject(pathWithNamespace string) *gitlab.Group {
+	for _, group := range c.groups {
+		if strings.HasPrefix(pathWithNamespace, group.FullPath+"/") {
+			return group
+		}
+	}
+	return nil
+}
+
+func isNotFound(resp *gitlab.Response) bool {
+	return resp != nil && resp.StatusCode == http.StatusNotFound
+}
+
+// refreshIssue indexes the current state of an issue. If it was deleted or can no longer be seen, it is removed by its ID
+func (c *DBClient) refreshIssue(ctx context.Context, group *gitlab.Group, projectID, iid, id int) error {
+	issue, resp, err := c.gitlabClient.Issues.GetIssue(projectID, iid, gitlab.WithContext(ctx))
+	if err != nil {
+		if isNotFound(resp) {
+			return c.deleteItems(ctx, []string{ItemID(ItemKindIssue, id)})
+		}
+		return fmt.Errorf("failed to get issue: %w", err)
+	}
+
+	// Confidential and moved issues are never indexed, so an issue that just became one must disappear
+	if issue.Confidential || issue.MovedToID != 0 {
+		return c.deleteItems(ctx, []string{ItemID(ItemKindIssue, issue.ID)})
+	}
+
+	return c.upsertItemsWithRelations(ctx, []GitLabItem{FromGitLabIssue(issue, group.ID)})
+}
+
+// refreshMergeRequest indexes the current state of a merge request, or removes it like refreshIssue
+func (c *DBClient) refreshMergeRequest(ctx context.Context, group *gitlab.Group, projectID, iid, id int) error {
+	mr, resp, err := c.gitlabClient.MergeRequests.GetMergeRequest(projectID, iid, nil, gitlab.WithContext(ctx))
+	if err != nil {
+		if isNotFound(resp) {
+			return c.deleteItems(ctx, []string{ItemID(ItemKindMergeRequest, id)})
+		}
+		return fmt.Errorf("failed to get merge request: %w", err)
+	}
+
+	return c.upsertItemsWithRelations(ctx, []GitLabItem{FromGitLabMergeRequest(&mr.BasicMergeRequest, group.ID)})
+}
+
+func (c *DBClient) refreshMember(ctx context.Context, group *gitlab.Group, userID int) error {
+	member, resp, err := c.gitlabClient.GroupMembers.GetGroupMember(group.ID, userID, gitlab.WithContext(ctx))
+	if err != nil {
+		if isNotFound(resp) {
+			// The user was removed from the group. Like the background sync, we keep known users around
+			return nil
+		}
+		return fmt.Errorf("failed to get group member: %w", err)
+	}
+
+	return c.upsertUsers([]User{FromGitLabGroupMember(member)})
+}
+
+// requestEpicSync makes the background worker sync the epics. Requests arriving while a sync is pending are merged
+func (c *DBClient) requestEpicSync() {
+	select {
+	case c.epicSyncRequests <- struct{}{}:
+	default:
+	}
+}
+
+// syncEpicsInBackground runs the epic syncs requested by webhooks until ctx is cancelled
+func (c *DBClient) syncEpicsInBackground(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.epicSyncRequests:
+			if err := c.syncEpics(ctx); err != nil {
+				c.logger.Printf("Failed to sync epics: %v", err)
+			}
+		}
+	}
+}
+
+// syncEpics fetches the recently changed epics of all groups. Epic events don't carry enough
+// information to fetch the single epic, but the incremental sync only requests what changed
+func (c *DBClient) syncEpics(ctx context.Context) error {
+	index := c.client.Index(ITEMS_INDEX)
+
+	for _, group := range c.groups {
+		newest := c.findNewestUpdate(index, group, ItemKindEpic)
+
+		epics, err := listAllGroupEpics(c.gitlabClient, group.ID, newest, gitlab.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to list epics of group %q: %w", group.Name, err)
+		}
+
+		if len(epics) == 0 {
+			continue
+		}
+
+		var items = make([]GitLabItem, 0, len(epics))
+		for _, epic := range epics {
+			items = append(items, FromGitLabEpic(epic, group.ID))
+		}
+
+		if err := c.upsertItems(items); err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteItems removes the items with the given IDs from the index and notifies the remove callback
+func (c *DBClient) deleteItems(ctx context.Context, ids []string) error {
+	index := c.client.Index(ITEMS_INDEX)
+
+	task, err := index.DeleteDocumentsWithContext(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to delete items: %w", err)
+	}
+
+	res, err := c.client.WaitForTaskWithContext(ctx, task.TaskUID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to wait for task: %w", err)
+	}
+
+	if res.Status != meilisearch.TaskStatusSucceeded {
+		return fmt.Errorf("item delete task was not successful: %q", res.Status)
+	}
+
+	c.removeItemCallback(ids)
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package web
+
+import (
+	"pathflux/graph"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AnalyzeGraph reports cycles, the topological order and the critical path of a graph.
+// The estimate query parameter selects weight, time or count, by default the first one any node has
+func (s *Server) AnalyzeGraph(c *fiber.Ctx) error {
+	kind, err := graph.ParseEstimateKind(c.Query("estimate"))
+	if err != nil {
+		return graphError(err)
+	}
+
+	// Estimates come from the GitLab items, so the analysis runs on the hydrated copy
+	g, err := s.hydratedGraph(c.Context(), currentSession(c).UserID, c.Params("id"))
+	if err != nil {
+		return graphError(err)
+	}
+
+	return c.JSON(g.Analyze(kind))
+}
@@ -1,13 +1,98 @@
 package web
 
 import (
+	"errors"
 	"pathflux/meili"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+func parsePage(c *fiber.Ctx) (meili.Page, error) {
+	page, err := meili.ParsePage(c.Query("limit"), c.Query("offset"))
+	if err != nil {
+		return page, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return page, nil
+}
+
+// queryValues returns all values of a repeated query parameter
+func queryValues(c *fiber.Ctx, key string) (values []string) {
+	for _, v := range c.Context().QueryArgs().PeekMulti(key) {
+		if len(v) > 0 {
+			values = append(values, string(v))
+		}
+	}
+	return values
+}
+
+func parseTimeRange(c *fiber.Ctx, prefix string) (r meili.TimeRange, err error) {
+	var parse = func(key string) (*time.Time, error) {
+		value := c.Query(key)
+		if value == "" {
+			return nil, nil
+		}
+		t, err := meili.ParseDate(value)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, key+": "+err.Error())
+		}
+		return &t, nil
+	}
+
+	if r.After, err = parse(prefix + "_after"); err != nil {
+		return r, err
+	}
+	if r.Before, err = parse(prefix + "_before"); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// parseItemFilter reads the item filter from the query. "label" and "user" can be repeated and must all match,
+// "kind" and "group_id" are comma separated and match any of their values. Dates are filtered with
+// created_after, created_before, updated_after, updated_before, closed_after and closed_before
+func parseItemFilter(c *fiber.Ctx) (filter meili.ItemFilter, err error) {
+	filter.State = meili.ParseItemState(c.Query("state"))
+	filter.Labels = queryValues(c, "label")
+	filter.Users = queryValues(c, "user")
+
+	for _, k := range splitList(c.Query("kind")) {
+		kind, ok := meili.ParseItemKind(k)
+		if !ok {
+			return filter, fiber.NewError(fiber.StatusBadRequest, "unknown kind "+strconv.Quote(k))
+		}
+		filter.Kinds = append(filter.Kinds, kind)
+	}
+
+	for _, g := range splitList(c.Query("group_id")) {
+		id, err := strconv.Atoi(g)
+		if err != nil {
+			return filter, fiber.NewError(fiber.StatusBadRequest, "group_id "+strconv.Quote(g)+" is not a number")
+		}
+		filter.GroupIDs = append(filter.GroupIDs, id)
+	}
+
+	if filter.Created, err = parseTimeRange(c, "created"); err != nil {
+		return filter, err
+	}
+	if filter.Updated, err = parseTimeRange(c, "updated"); err != nil {
+		return filter, err
+	}
+	if filter.Closed, err = parseTimeRange(c, "closed"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
 func (s *Server) SearchUsers(c *fiber.Ctx) error {
-	users, err := s.DB.SearchUsers(c.Context(), c.Query("q"))
+	page, err := parsePage(c)
+	if err != nil {
+		return err
+	}
+
+	users, err := s.DB.SearchUsers(c.Context(), c.Query("q"), page)
 	if err != nil {
 		return err
 	}
@@ -15,11 +100,155 @@ func (s *Server) SearchUsers(c *fiber.Ctx) error {
 }
 
 func (s *Server) SearchItems(c *fiber.Ctx) error {
-	state := meili.ParseItemState(c.Query("state"))
+	page, err := parsePage(c)
+	if err != nil {
+		return err
+	}
+
+	filter, err := parseItemFilter(c)
+	if err != nil {
+		return err
+	}
+
+	query := c.Query("q")
+
+	parsed, err := meili.ParseQuery(query)
+	if err != nil {
+		var queryErrs meili.QueryErrors
+		if errors.As(err, &queryErrs) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":  err.Error(),
+				"errors": queryErrs,
+			})
+		}
+		return err
+	}
+	if parsed.HasQualifiers {
+		query = parsed.Text
+		filter.Merge(parsed.Filter)
+	}
+
 	sort := meili.ParseSort(c.Query("sort"))
-	items, err := s.DB.SearchItems(c.Context(), c.Query("q"), state, sort)
+	items, err := s.DB.SearchItems(c.Context(), currentSession(c).UserID, query, filter, sort, page)
 	if err != nil {
 		return err
 	}
 	return c.JSON(items)
 }
+
+// ResolveItem returns the item a GitLab reference like group/project#12 or a web URL points to
+func (s *Server) ResolveItem(c *fiber.Ctx) error {
+	item, err := s.DB.ResolveReference(c.Context(), currentSession(c).UserID, c.Query("ref"))
+	switch {
+	case errors.Is(err, meili.ErrInvalidReference):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, meili.ErrItemNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case err != nil:
+		return err
+	}
+	return c.JSON(item)
+}
+
+func (s *Server) GetUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "user ID must be a number")
+	}
+
+	user, err := s.DB.GetUserByID(c.Context(), id)
+	if errors.Is(err, meili.ErrUserNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(user)
+}
+
+func (s *Server) GetItem(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, _, ok := meili.ParseItemID(id); !ok {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid item ID "+strconv.Quote(id))
+	}
+
+	item, err := s.DB.GetItemByID(c.Context(), currentSession(c).UserID, id)
+	if errors.Is(err, meili.ErrItemNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(item)
+}
+
+type itemRelationsResponse struct {
+	ItemID    string               `json:"item_id"`
+	Relations []meili.ItemRelation `json:"relations"`
+}
+
+func (s *Server) GetItemRelations(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, _, ok := meili.ParseItemID(id); !ok {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid item ID "+strconv.Quote(id))
+	}
+
+	relations, err := s.DB.GetItemRelations(c.Context(), currentSession(c).UserID, id)
+	if errors.Is(err, meili.ErrItemNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(itemRelationsResponse{
+		ItemID:    id,
+		Relations: relations,
+	})
+}
+
+// maxBatchIDs limits how many items can be requested at once
+const maxBatchIDs = 1000
+
+type batchItemsRequest struct {
+	IDs []string `json:"ids"`
+}
+
+type batchItemsResponse struct {
+	Items map[string]meili.GitLabItem `json:"items"`
+	// Missing lists the requested IDs that don't exist or aren't visible to the user
+	Missing []string `json:"missing"`
+}
+
+// GetItemsBatch returns many items at once, e.g. to refresh all items shown on a graph
+func (s *Server) GetItemsBatch(c *fiber.Ctx) error {
+	var req batchItemsRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if len(req.IDs) > maxBatchIDs {
+		return fiber.NewError(fiber.StatusBadRequest, "at most "+strconv.Itoa(maxBatchIDs)+" IDs can be requested at once")
+	}
+	for _, id := range req.IDs {
+		if _, _, ok := meili.ParseItemID(id); !ok {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid item ID "+strconv.Quote(id))
+		}
+	}
+
+	items, err := s.DB.GetItemsByIDs(c.Context(), currentSession(c).UserID, req.IDs)
+	if err != nil {
+		return err
+	}
+
+	var missing = []string{}
+	for _, id := range req.IDs {
+		if _, ok := items[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+
+	return c.JSON(batchItemsResponse{
+		Items:   items,
+		Missing: missing,
+	})
+}
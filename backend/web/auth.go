@@ -0,0 +1,204 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"pathflux/meili"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oauthStateCookieName = "pathflux_oauth_state"
+	sessionLocalsKey     = "session"
+)
+
+func (s *Server) oauthConfig() *oauth2.Config {
+	instanceURL := strings.TrimSuffix(s.Cfg.GitLab.InstanceURL, "/")
+
+	return &oauth2.Config{
+		ClientID:     s.Cfg.GitLab.ApplicationID,
+		ClientSecret: s.Cfg.GitLab.ApplicationSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  instanceURL + "/oauth/authorize",
+			TokenURL: instanceURL + "/oauth/token",
+		},
+		RedirectURL: strings.TrimSuffix(s.Cfg.HostExternalURL, "/") + "/auth/callback",
+		Scopes:      []string{"read_user"},
+	}
+}
+
+func randomToken() (string, error) {
+	var b = make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (s *Server) secureCookies() bool {
+	return strings.HasPrefix(s.Cfg.HostExternalURL, "https://")
+}
+
+// Login redirects to GitLab to start the OAuth authorization code flow
+func (s *Server) Login(c *fiber.Ctx) error {
+	state, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookieName,
+		Value:    state,
+		Path:     "/auth",
+		Expires:  time.Now().Add(10 * time.Minute),
+		HTTPOnly: true,
+		Secure:   s.secureCookies(),
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return c.Redirect(s.oauthConfig().AuthCodeURL(state), fiber.StatusFound)
+}
+
+// OAuthCallback finishes the OAuth flow and sets the session cookie
+func (s *Server) OAuthCallback(c *fiber.Ctx) error {
+	state := c.Cookies(oauthStateCookieName)
+	c.ClearCookie(oauthStateCookieName)
+
+	if state == "" || c.Query("state") != state {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid OAuth state, please try logging in again")
+	}
+
+	if errMsg := c.Query("error"); errMsg != "" {
+		return fiber.NewError(fiber.StatusUnauthorized, "GitLab login failed: "+errMsg)
+	}
+
+	token, err := s.oauthConfig().Exchange(c.Context(), c.Query("code"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "failed to exchange OAuth code: "+err.Error())
+	}
+
+	gc, err := gitlab.NewOAuthClient(token.AccessToken, gitlab.WithBaseURL(s.Cfg.GitLab.InstanceURL))
+	if err != nil {
+		return err
+	}
+
+	user, _, err := gc.Users.CurrentUser(gitlab.WithContext(c.Context()))
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "failed to get GitLab user: "+err.Error())
+	}
+
+	if user.State != "active" {
+		return fiber.NewError(fiber.StatusForbidden, "GitLab user is not active")
+	}
+
+	now := time.Now()
+	err = s.setSessionCookie(c, Session{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Name:      user.Name,
+		AvatarURL: user.AvatarURL,
+		ExpiresAt: now.Add(sessionDuration).Unix(),
+		CheckedAt: now.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect("/", fiber.StatusFound)
+}
+
+// setSessionCookie signs the session and sets it as a cookie that expires with it
+func (s *Server) setSessionCookie(c *fiber.Ctx, session Session) error {
+	value, err := encodeSession(s.sessionSecret, session)
+	if err != nil {
+		return err
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  time.Unix(session.ExpiresAt, 0),
+		HTTPOnly: true,
+		Secure:   s.secureCookies(),
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return nil
+}
+
+// Logout clears the session cookie. It only accepts POST requests, so other sites can't log users out with links
+func (s *Server) Logout(c *fiber.Ctx) error {
+	s.clearSessionCookie(c)
+	return c.Redirect("/", fiber.StatusSeeOther)
+}
+
+func (s *Server) clearSessionCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		Secure:   s.secureCookies(),
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// RequireSession rejects requests without a valid session cookie. Every sessionCheckInterval the user is looked up
+// in GitLab again, so users that were blocked or deleted lose access before their session expires
+func (s *Server) RequireSession(c *fiber.Ctx) error {
+	session, err := decodeSession(s.sessionSecret, c.Cookies(sessionCookieName))
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "not logged in")
+	}
+
+	if time.Since(time.Unix(session.CheckedAt, 0)) > sessionCheckInterval {
+		session, err = s.recheckSession(c, session)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.Locals(sessionLocalsKey, session)
+
+	return c.Next()
+}
+
+// recheckSession looks up the session's user in GitLab and renews the session cookie with the current user details.
+// If GitLab can't be reached the session is kept and checked again with the next request
+func (s *Server) recheckSession(c *fiber.Ctx, session Session) (Session, error) {
+	user, err := s.DB.FetchGitLabUser(c.Context(), session.UserID)
+	if errors.Is(err, meili.ErrUserNotFound) || (err == nil && user.State != "active") {
+		s.clearSessionCookie(c)
+		return Session{}, fiber.NewError(fiber.StatusUnauthorized, "GitLab user is not active")
+	}
+	if err != nil {
+		log.Printf("failed to check the session of user %d: %v", session.UserID, err)
+		return session, nil
+	}
+
+	session.Username, session.Name, session.AvatarURL = user.Username, user.Name, user.AvatarURL
+	session.CheckedAt = time.Now().Unix()
+	if err := s.setSessionCookie(c, session); err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// currentSession returns the session set by RequireSession
+func currentSession(c *fiber.Ctx) Session {
+	session, _ := c.Locals(sessionLocalsKey).(Session)
+	return session
+}
+
+// CurrentUser returns the logged in user
+func (s *Server) CurrentUser(c *fiber.Ctx) error {
+	return c.JSON(currentSession(c))
+}
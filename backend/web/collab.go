@@ -0,0 +1,121 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"pathflux/graph"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// clientIDHeader lets clients tag their operations, so they can ignore them when they are broadcast back
+const clientIDHeader = "X-Client-ID"
+
+// GraphEvents streams all operations on a graph as Server-Sent Events. Each event ID is the graph version,
+// so a reconnecting EventSource sends Last-Event-ID and only receives the operations it missed.
+// The "since" query parameter can be used instead of the header
+func (s *Server) GraphEvents(c *fiber.Ctx) error {
+	since := c.Get("Last-Event-ID", c.Query("since"))
+
+	var version uint64
+	if since != "" {
+		var err error
+		version, err = strconv.ParseUint(since, 10, 64)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid version "+strconv.Quote(since))
+		}
+	}
+
+	sub, err := s.Graphs.Subscribe(c.Params("id"), version)
+	if err != nil {
+		return graphError(err)
+	}
+
+	return streamEvents(c, func(w *bufio.Writer) {
+		defer sub.Close()
+
+		if sub.Snapshot != nil {
+			if writeEvent(w, "snapshot", strconv.FormatUint(sub.Version, 10), sub.Snapshot) != nil {
+				return
+			}
+		}
+
+		for _, op := range sub.Missed {
+			if writeEvent(w, "operation", strconv.FormatUint(op.Version, 10), op) != nil {
+				return
+			}
+		}
+
+		keepAlive := time.NewTicker(sseKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case op, ok := <-sub.Operations():
+				if !ok {
+					// Either the graph was deleted or we fell behind; the client reconnects and catches up
+					return
+				}
+				if writeEvent(w, "operation", strconv.FormatUint(op.Version, 10), op) != nil {
+					return
+				}
+			case <-keepAlive.C:
+				if writeKeepAlive(w) != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// ApplyOperations applies one or more operations, sent as a single object or an array, in order.
+// It stops at the first failing operation and returns the operations applied so far with their versions
+func (s *Server) ApplyOperations(c *fiber.Ctx) error {
+	var ops []*graph.Operation
+
+	body := c.Body()
+	if len(body) > 0 && body[0] == '{' {
+		var op graph.Operation
+		if err := json.Unmarshal(body, &op); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid operation: "+err.Error())
+		}
+		ops = append(ops, &op)
+	} else if err := json.Unmarshal(body, &ops); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid operations: "+err.Error())
+	}
+
+	clientID := c.Get(clientIDHeader)
+	userID := currentSession(c).UserID
+
+	var applied = make([]*graph.Operation, 0, len(ops))
+	for _, op := range ops {
+		if op.ClientID == "" {
+			op.ClientID = clientID
+		}
+		op.UserID = userID
+
+		if err := s.Graphs.Apply(c.Params("id"), op); err != nil {
+			err = graphError(err)
+			if len(applied) == 0 {
+				return err
+			}
+
+			status := fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+			return c.Status(status).JSON(fiber.Map{
+				"error":   err.Error(),
+				"applied": applied,
+			})
+		}
+
+		applied = append(applied, op)
+	}
+
+	return c.JSON(applied)
+}
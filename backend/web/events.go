@@ -0,0 +1,90 @@
+package web
+
+import (
+	"bufio"
+	"pathflux/events"
+	"pathflux/meili"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// splitList splits a comma separated query parameter, ignoring empty entries
+func splitList(value string) []string {
+	var out []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func parseEventFilter(c *fiber.Ctx) (filter events.Filter, err error) {
+	if ids := splitList(c.Query("items")); len(ids) > 0 {
+		filter.ItemIDs = make(map[string]struct{}, len(ids))
+		for _, id := range ids {
+			filter.ItemIDs[id] = struct{}{}
+		}
+	}
+
+	if groups := splitList(c.Query("group_id")); len(groups) > 0 {
+		filter.GroupIDs = make(map[int]struct{}, len(groups))
+		for _, g := range groups {
+			id, err := strconv.Atoi(g)
+			if err != nil {
+				return filter, fiber.NewError(fiber.StatusBadRequest, "group_id "+strconv.Quote(g)+" is not a number")
+			}
+			filter.GroupIDs[id] = struct{}{}
+		}
+	}
+
+	if kinds := splitList(c.Query("kind")); len(kinds) > 0 {
+		filter.Kinds = make(map[meili.ItemKind]struct{}, len(kinds))
+		for _, k := range kinds {
+			kind, ok := meili.ParseItemKind(k)
+			if !ok {
+				return filter, fiber.NewError(fiber.StatusBadRequest, "unknown kind "+strconv.Quote(k))
+			}
+			filter.Kinds[kind] = struct{}{}
+		}
+	}
+
+	return filter, nil
+}
+
+// IndexEvents streams item and user updates from the index as Server-Sent Events, limited to the items the user can see.
+// Item events can be restricted with the comma separated "items", "group_id" and "kind" query parameters
+func (s *Server) IndexEvents(c *fiber.Ctx) error {
+	filter, err := parseEventFilter(c)
+	if err != nil {
+		return err
+	}
+
+	sub := s.Events.Subscribe(currentSession(c).UserID, filter)
+
+	return streamEvents(c, func(w *bufio.Writer) {
+		defer sub.Close()
+
+		keepAlive := time.NewTicker(sseKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case ev, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				if writeEvent(w, string(ev.Type), "", ev) != nil {
+					return
+				}
+			case <-keepAlive.C:
+				if writeKeepAlive(w) != nil {
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package web
+
+import (
+	"bytes"
+	"pathflux/graph"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExportGraph renders a graph as Mermaid, DOT or GraphML, selected by the format query parameter.
+// With download=true the response is sent as a file attachment
+func (s *Server) ExportGraph(c *fiber.Ctx) error {
+	format, err := graph.ParseExportFormat(c.Query("format"))
+	if err != nil {
+		return graphError(err)
+	}
+
+	g, err := s.hydratedGraph(c.Context(), currentSession(c).UserID, c.Params("id"))
+	if err != nil {
+		return graphError(err)
+	}
+
+	var buf bytes.Buffer
+	if err := g.Export(&buf, format); err != nil {
+		return graphError(err)
+	}
+
+	if c.QueryBool("download") {
+		c.Attachment(g.Name + "." + format.Extension())
+	}
+	c.Set(fiber.HeaderContentType, format.ContentType())
+	return c.Send(buf.Bytes())
+}
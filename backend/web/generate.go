@@ -0,0 +1,153 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"pathflux/graph"
+	"pathflux/meili"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type generateGraphRequest struct {
+	Name string `json:"name"`
+
+	// Exactly one of Root, Milestone and Query selects the items the graph starts from
+	Root      string `json:"root"`
+	Milestone string `json:"milestone"`
+	Query     string `json:"query"`
+
+	Depth     *int                 `json:"depth"`
+	Relations []meili.RelationType `json:"relations"`
+}
+
+func (r *generateGraphRequest) validate() error {
+	r.Root = strings.TrimSpace(r.Root)
+	r.Milestone = strings.TrimSpace(r.Milestone)
+	r.Query = strings.TrimSpace(r.Query)
+
+	var sources int
+	for _, v := range []string{r.Root, r.Milestone, r.Query} {
+		if v != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fiber.NewError(fiber.StatusBadRequest, "exactly one of root, milestone and query must be set")
+	}
+
+	for _, t := range r.Relations {
+		if !t.Valid() {
+			return fiber.NewError(fiber.StatusBadRequest, "unknown relation type "+strconv.Quote(string(t)))
+		}
+	}
+
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		switch {
+		case r.Root != "":
+			r.Name = r.Root
+		case r.Milestone != "":
+			r.Name = "Milestone " + r.Milestone
+		default:
+			r.Name = r.Query
+		}
+	}
+
+	return nil
+}
+
+// rootItems returns the IDs of the items the requested graph starts from
+func (s *Server) rootItems(ctx context.Context, userID int, r *generateGraphRequest) ([]string, error) {
+	switch {
+	case r.Root != "":
+		if _, _, ok := meili.ParseItemID(r.Root); ok {
+			item, err := s.DB.GetItemByID(ctx, userID, r.Root)
+			if err != nil {
+				return nil, err
+			}
+			return []string{item.ID}, nil
+		}
+
+		item, err := s.DB.ResolveReference(ctx, userID, r.Root)
+		if err != nil {
+			return nil, err
+		}
+		return []string{item.ID}, nil
+	case r.Milestone != "":
+		return s.DB.MilestoneItemIDs(ctx, userID, r.Milestone)
+	default:
+		parsed, err := meili.ParseQuery(r.Query)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		result, err := s.DB.SearchItems(ctx, userID, parsed.Text, parsed.Filter, meili.SortRelevance, meili.Page{Limit: meili.MaxSearchLimit})
+		if err != nil {
+			return nil, err
+		}
+
+		var ids = make([]string, 0, len(result.Hits))
+		for _, item := range result.Hits {
+			ids = append(ids, item.ID)
+		}
+		return ids, nil
+	}
+}
+
+// GenerateGraph creates a new graph from the relations of a root item, a milestone or a search query
+func (s *Server) GenerateGraph(c *fiber.Ctx) error {
+	var req generateGraphRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	if err := req.validate(); err != nil {
+		return err
+	}
+
+	userID := currentSession(c).UserID
+
+	roots, err := s.rootItems(c.Context(), userID, &req)
+	switch {
+	case errors.Is(err, meili.ErrInvalidReference):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, meili.ErrItemNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case err != nil:
+		return err
+	}
+	if len(roots) == 0 {
+		return fiber.NewError(fiber.StatusNotFound, "no items match")
+	}
+
+	depth := graph.DefaultGenerateDepth
+	if req.Depth != nil {
+		depth = *req.Depth
+	}
+
+	relations := func(ctx context.Context, itemID string) ([]meili.ItemRelation, error) {
+		return s.DB.GetItemRelations(ctx, userID, itemID)
+	}
+
+	g, err := graph.Generate(c.Context(), relations, graph.GenerateOptions{
+		Name:      req.Name,
+		Roots:     roots,
+		Depth:     depth,
+		Relations: req.Relations,
+	})
+	if err != nil {
+		return graphError(err)
+	}
+
+	if err := s.Graphs.Add(g); err != nil {
+		return err
+	}
+
+	hydrated, err := s.hydratedGraph(c.Context(), userID, g.ID)
+	if err != nil {
+		return graphError(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(hydrated)
+}
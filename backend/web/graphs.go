@@ -0,0 +1,289 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"pathflux/graph"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// graphError converts errors from the graph package into HTTP errors
+func graphError(err error) error {
+	switch {
+	case errors.Is(err, graph.ErrGraphNotFound), errors.Is(err, graph.ErrNodeNotFound), errors.Is(err, graph.ErrEdgeNotFound),
+		errors.Is(err, graph.ErrVersionNotFound), errors.Is(err, graph.ErrSnapshotNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, graph.ErrNothingToUndo), errors.Is(err, graph.ErrNothingToRedo), errors.Is(err, graph.ErrConflict):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, graph.ErrInvalid):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		return err
+	}
+}
+
+func parseBody(c *fiber.Ctx, out any) error {
+	if err := c.BodyParser(out); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body: "+err.Error())
+	}
+	return nil
+}
+
+type graphNameRequest struct {
+	Name string `json:"name"`
+}
+
+func (r *graphNameRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "graph name must not be empty")
+	}
+	return nil
+}
+
+func (s *Server) ListGraphs(c *fiber.Ctx) error {
+	return c.JSON(s.Graphs.List())
+}
+
+func (s *Server) CreateGraph(c *fiber.Ctx) error {
+	var req graphNameRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	if err := req.validate(); err != nil {
+		return err
+	}
+
+	g, err := s.Graphs.Create(req.Name)
+	if err != nil {
+		return err
+	}
+
+	return s.Graphs.View(g.ID, func(g *graph.Graph) error {
+		return c.Status(fiber.StatusCreated).JSON(g)
+	})
+}
+
+// hydratedGraph returns a copy of the graph with the current state of all referenced GitLab items filled in
+func (s *Server) hydratedGraph(ctx context.Context, userID int, id string) (*graph.Graph, error) {
+	var g *graph.Graph
+	err := s.Graphs.View(id, func(orig *graph.Graph) error {
+		g = orig.Clone()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return g, s.hydrate(ctx, userID, g)
+}
+
+// hydrate fills in the current state of the GitLab items referenced by a copy of a graph
+func (s *Server) hydrate(ctx context.Context, userID int, g *graph.Graph) error {
+	ids := g.ItemIDs()
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// Items the user can't see in GitLab stay empty
+	items, err := s.DB.GetItemsByIDs(ctx, userID, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range g.Nodes {
+		if n.Type != graph.NodeTypeGitLabItem {
+			continue
+		}
+		if item, ok := items[n.ItemID]; ok {
+			n.Item = &item
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) GetGraph(c *fiber.Ctx) error {
+	g, err := s.hydratedGraph(c.Context(), currentSession(c).UserID, c.Params("id"))
+	if err != nil {
+		return graphError(err)
+	}
+	return c.JSON(g)
+}
+
+func (s *Server) RenameGraph(c *fiber.Ctx) error {
+	var req graphNameRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	if err := req.validate(); err != nil {
+		return err
+	}
+
+	err := s.applyAndRespond(c, &graph.Operation{
+		Type: graph.OpRename,
+		Name: req.Name,
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	return s.GetGraph(c)
+}
+
+func (s *Server) DeleteGraph(c *fiber.Ctx) error {
+	if err := s.Graphs.Delete(c.Params("id")); err != nil {
+		return graphError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// applyAndRespond applies the operation and, if it succeeded, lets respond write the response from the current graph
+func (s *Server) applyAndRespond(c *fiber.Ctx, op *graph.Operation, respond func(g *graph.Graph) error) error {
+	op.ClientID = c.Get(clientIDHeader)
+	op.UserID = currentSession(c).UserID
+
+	if err := s.Graphs.Apply(c.Params("id"), op); err != nil {
+		return graphError(err)
+	}
+
+	if respond == nil {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	return graphError(s.Graphs.View(c.Params("id"), respond))
+}
+
+func (s *Server) AddNode(c *fiber.Ctx) error {
+	var node graph.Node
+	if err := parseBody(c, &node); err != nil {
+		return err
+	}
+
+	op := &graph.Operation{
+		Type: graph.OpAddNode,
+		Node: &node,
+	}
+	if err := s.applyAndRespond(c, op, nil); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(op.Node)
+}
+
+type nodeUpdateRequest struct {
+	Position    *graph.Position `json:"position"`
+	Content     *string         `json:"content"`
+	ContentEdit graph.TextEdit  `json:"content_edit"`
+	// BaseVersion is the graph version the change was made at, so it can be merged with concurrent changes
+	BaseVersion uint64 `json:"base_version"`
+}
+
+func (s *Server) UpdateNode(c *fiber.Ctx) error {
+	var req nodeUpdateRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	nodeID := c.Params("nodeId")
+	return s.applyAndRespond(c, &graph.Operation{
+		Type:        graph.OpUpdateNode,
+		NodeID:      nodeID,
+		Position:    req.Position,
+		Content:     req.Content,
+		ContentEdit: req.ContentEdit,
+		BaseVersion: req.BaseVersion,
+	}, func(g *graph.Graph) error {
+		node := g.Node(nodeID)
+		if node == nil {
+			return graph.ErrNodeNotFound
+		}
+		return c.JSON(node)
+	})
+}
+
+func (s *Server) DeleteNode(c *fiber.Ctx) error {
+	return s.applyAndRespond(c, &graph.Operation{
+		Type:   graph.OpRemoveNode,
+		NodeID: c.Params("nodeId"),
+	}, nil)
+}
+
+func (s *Server) AddEdge(c *fiber.Ctx) error {
+	var edge graph.Edge
+	if err := parseBody(c, &edge); err != nil {
+		return err
+	}
+
+	op := &graph.Operation{
+		Type: graph.OpAddEdge,
+		Edge: &edge,
+	}
+	if err := s.applyAndRespond(c, op, nil); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(op.Edge)
+}
+
+type edgeUpdateRequest struct {
+	Type      *string `json:"type"`
+	Source    *string `json:"source"`
+	Target    *string `json:"target"`
+	MarkerEnd *string `json:"markerEnd"`
+	Label     *string `json:"label"`
+}
+
+func (s *Server) UpdateEdge(c *fiber.Ctx) error {
+	var req edgeUpdateRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	edgeID := c.Params("edgeId")
+	clientID := c.Get(clientIDHeader)
+	userID := currentSession(c).UserID
+
+	// The edge is read and replaced under the same lock, so concurrent updates of other fields aren't lost
+	applied, err := s.Graphs.ApplyComputed(c.Params("id"), func(g *graph.Graph) ([]*graph.Operation, error) {
+		existing := g.Edge(edgeID)
+		if existing == nil {
+			return nil, graph.ErrEdgeNotFound
+		}
+
+		edge := *existing
+		if req.Type != nil {
+			edge.Type = *req.Type
+		}
+		if req.Source != nil {
+			edge.Source = *req.Source
+		}
+		if req.Target != nil {
+			edge.Target = *req.Target
+		}
+		if req.MarkerEnd != nil {
+			edge.MarkerEnd = *req.MarkerEnd
+		}
+		if req.Label != nil {
+			edge.Label = *req.Label
+		}
+
+		return []*graph.Operation{{
+			Type:     graph.OpUpdateEdge,
+			ClientID: clientID,
+			UserID:   userID,
+			Edge:     &edge,
+		}}, nil
+	})
+	if err != nil {
+		return graphError(err)
+	}
+	return c.JSON(applied[0].Edge)
+}
+
+func (s *Server) DeleteEdge(c *fiber.Ctx) error {
+	return s.applyAndRespond(c, &graph.Operation{
+		Type:   graph.OpRemoveEdge,
+		EdgeID: c.Params("edgeId"),
+	}, nil)
+}
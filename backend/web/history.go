@@ -0,0 +1,201 @@
+package web
+
+import (
+	"pathflux/graph"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+// versionParam parses a graph version from the route or query. Missing versions return ok = false
+func versionParam(s, name string) (version uint64, ok bool, err error) {
+	if s == "" {
+		return 0, false, nil
+	}
+	version, err = strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false, fiber.NewError(fiber.StatusBadRequest, "invalid "+name+": "+s)
+	}
+	return version, true, nil
+}
+
+// currentVersion returns the version the graph is at now
+func (s *Server) currentVersion(id string) (version uint64, err error) {
+	err = s.Graphs.View(id, func(g *graph.Graph) error {
+		version = g.Version
+		return nil
+	})
+	return version, err
+}
+
+// GraphHistory lists the recorded operations of a graph, newest first. Older entries are paged with
+// before, which returns only operations that produced a lower version
+func (s *Server) GraphHistory(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultHistoryLimit)
+	if limit <= 0 || limit > maxHistoryLimit {
+		return fiber.NewError(fiber.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxHistoryLimit))
+	}
+	before, hasBefore, err := versionParam(c.Query("before"), "before")
+	if err != nil {
+		return err
+	}
+
+	records, err := s.Graphs.History(c.Params("id"))
+	if err != nil {
+		return graphError(err)
+	}
+
+	var page = make([]graph.HistoryRecord, 0, min(limit, len(records)))
+	for i := len(records) - 1; i >= 0 && len(page) < limit; i-- {
+		if hasBefore && records[i].Operation.Version >= before {
+			continue
+		}
+		page = append(page, records[i])
+	}
+
+	return c.JSON(page)
+}
+
+// GetGraphVersion returns the graph as it was at a version, with the current state of its GitLab items
+func (s *Server) GetGraphVersion(c *fiber.Ctx) error {
+	version, _, err := versionParam(c.Params("version"), "version")
+	if err != nil {
+		return err
+	}
+
+	g, err := s.Graphs.AtVersion(c.Params("id"), version)
+	if err != nil {
+		return graphError(err)
+	}
+	if err := s.hydrate(c.Context(), currentSession(c).UserID, g); err != nil {
+		return err
+	}
+
+	return c.JSON(g)
+}
+
+// DiffGraph compares two versions of a graph. to defaults to the current version
+func (s *Server) DiffGraph(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	from, ok, err := versionParam(c.Query("from"), "from")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fiber.NewError(fiber.StatusBadRequest, "from is required")
+	}
+	to, ok, err := versionParam(c.Query("to"), "to")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if to, err = s.currentVersion(id); err != nil {
+			return graphError(err)
+		}
+	}
+
+	before, err := s.Graphs.AtVersion(id, from)
+	if err != nil {
+		return graphError(err)
+	}
+	after, err := s.Graphs.AtVersion(id, to)
+	if err != nil {
+		return graphError(err)
+	}
+
+	return c.JSON(graph.NewDiff(before, after))
+}
+
+type restoreRequest struct {
+	Version    *uint64 `json:"version"`
+	SnapshotID string  `json:"snapshot_id"`
+}
+
+// RestoreGraph brings a graph back to a version or snapshot. The restore is applied as operations,
+// which are broadcast to all viewers and returned, and becomes a new version in the history
+func (s *Server) RestoreGraph(c *fiber.Ctx) error {
+	var req restoreRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	if (req.Version == nil) == (req.SnapshotID == "") {
+		return fiber.NewError(fiber.StatusBadRequest, "either version or snapshot_id is required")
+	}
+
+	clientID := c.Get(clientIDHeader)
+	userID := currentSession(c).UserID
+
+	var applied []*graph.Operation
+	var err error
+	if req.Version != nil {
+		applied, err = s.Graphs.Restore(c.Params("id"), *req.Version, clientID, userID)
+	} else {
+		applied, err = s.Graphs.RestoreSnapshot(c.Params("id"), req.SnapshotID, clientID, userID)
+	}
+	if err != nil {
+		return graphError(err)
+	}
+
+	if applied == nil {
+		applied = []*graph.Operation{}
+	}
+	return c.JSON(applied)
+}
+
+func (s *Server) ListSnapshots(c *fiber.Ctx) error {
+	snapshots, err := s.Graphs.Snapshots(c.Params("id"))
+	if err != nil {
+		return graphError(err)
+	}
+	return c.JSON(snapshots)
+}
+
+type createSnapshotRequest struct {
+	Name    string  `json:"name"`
+	Version *uint64 `json:"version"`
+}
+
+// CreateSnapshot names a version of the graph, by default the current one
+func (s *Server) CreateSnapshot(c *fiber.Ctx) error {
+	var req createSnapshotRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "snapshot name must not be empty")
+	}
+
+	id := c.Params("id")
+
+	var version uint64
+	if req.Version != nil {
+		version = *req.Version
+	} else {
+		var err error
+		if version, err = s.currentVersion(id); err != nil {
+			return graphError(err)
+		}
+	}
+
+	snapshot, err := s.Graphs.CreateSnapshot(id, req.Name, version)
+	if err != nil {
+		return graphError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(snapshot)
+}
+
+func (s *Server) DeleteSnapshot(c *fiber.Ctx) error {
+	if err := s.Graphs.DeleteSnapshot(c.Params("id"), c.Params("snapshotId")); err != nil {
+		return graphError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
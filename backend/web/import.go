@@ -0,0 +1,54 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log"
+	"pathflux/graph"
+	"pathflux/meili"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImportGraph creates a new graph from a Mermaid flowchart or JSON Canvas file sent as the request body.
+// The format and name query parameters are optional, the format is guessed from the body if it is missing
+func (s *Server) ImportGraph(c *fiber.Ctx) error {
+	body := c.Body()
+	if len(body) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "request body must contain the file to import")
+	}
+
+	format, err := graph.ParseImportFormat(c.Query("format"), body)
+	if err != nil {
+		return graphError(err)
+	}
+
+	userID := currentSession(c).UserID
+
+	resolve := func(ctx context.Context, ref string) (string, error) {
+		item, err := s.DB.ResolveReference(ctx, userID, ref)
+		if errors.Is(err, meili.ErrInvalidReference) || errors.Is(err, meili.ErrItemNotFound) {
+			return "", nil
+		}
+		if err != nil {
+			log.Printf("failed to resolve %q while importing a graph: %v", ref, err)
+			return "", err
+		}
+		return item.ID, nil
+	}
+
+	g, err := graph.Import(c.Context(), body, format, c.Query("name"), resolve)
+	if err != nil {
+		return graphError(err)
+	}
+
+	if err := s.Graphs.Add(g); err != nil {
+		return err
+	}
+
+	hydrated, err := s.hydratedGraph(c.Context(), userID, g.ID)
+	if err != nil {
+		return graphError(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(hydrated)
+}
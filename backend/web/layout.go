@@ -0,0 +1,78 @@
+package web
+
+import (
+	"pathflux/graph"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type layoutRequest struct {
+	Algorithm    graph.LayoutAlgorithm `json:"algorithm"`
+	Direction    graph.LayoutDirection `json:"direction"`
+	NodeSpacing  float64               `json:"node_spacing"`
+	LayerSpacing float64               `json:"layer_spacing"`
+	NodeIDs      []string              `json:"node_ids"`
+}
+
+// LayoutGraph computes positions for all or the selected nodes and moves them there.
+// The moves are applied as update_node operations, so other viewers see them like any other change
+func (s *Server) LayoutGraph(c *fiber.Ctx) error {
+	// All options are optional, so an empty body lays out the whole graph with the defaults
+	var req layoutRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	clientID := c.Get(clientIDHeader)
+	userID := currentSession(c).UserID
+
+	// The layout is computed on a copy, so large graphs don't block other editors meanwhile.
+	// Nodes removed in between are skipped when the positions are applied
+	var snapshot *graph.Graph
+	err := s.Graphs.View(c.Params("id"), func(g *graph.Graph) error {
+		snapshot = g.Clone()
+		return nil
+	})
+	if err != nil {
+		return graphError(err)
+	}
+
+	positions, err := snapshot.Layout(graph.LayoutOptions{
+		Algorithm:    req.Algorithm,
+		Direction:    req.Direction,
+		NodeSpacing:  req.NodeSpacing,
+		LayerSpacing: req.LayerSpacing,
+		NodeIDs:      req.NodeIDs,
+	})
+	if err != nil {
+		return graphError(err)
+	}
+
+	applied, err := s.Graphs.ApplyComputed(c.Params("id"), func(g *graph.Graph) ([]*graph.Operation, error) {
+		var ops []*graph.Operation
+		for _, n := range g.Nodes {
+			p, ok := positions[n.ID]
+			if !ok || p == n.Position {
+				continue
+			}
+			ops = append(ops, &graph.Operation{
+				Type:     graph.OpUpdateNode,
+				ClientID: clientID,
+				UserID:   userID,
+				NodeID:   n.ID,
+				Position: &p,
+			})
+		}
+		return ops, nil
+	})
+	if err != nil {
+		return graphError(err)
+	}
+
+	if applied == nil {
+		applied = []*graph.Operation{}
+	}
+	return c.JSON(applied)
+}
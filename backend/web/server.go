@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"os"
 	"pathflux/config"
+	"pathflux/events"
+	"pathflux/graph"
 	"pathflux/meili"
 	"strconv"
 
@@ -14,8 +16,13 @@ import (
 )
 
 type Server struct {
-	Cfg *config.Config
-	DB  *meili.DBClient
+	Cfg    *config.Config
+	DB     *meili.DBClient
+	Graphs *graph.Manager
+	Events *events.Hub
+
+	// sessionSecret signs session cookies
+	sessionSecret []byte
 }
 
 func (s *Server) Run() (err error) {
@@ -23,9 +30,72 @@ func (s *Server) Run() (err error) {
 		AppName: "PathFlux",
 	})
 
+	if s.Cfg.SessionSecret != "" {
+		s.sessionSecret = []byte(s.Cfg.SessionSecret)
+	} else {
+		secret, err := randomToken()
+		if err != nil {
+			return err
+		}
+		s.sessionSecret = []byte(secret)
+		log.Println("SESSION_SECRET is not set, sessions will not survive a restart")
+	}
+
+	auth := app.Group("/auth")
+	auth.Get("/login", s.Login)
+	auth.Get("/callback", s.OAuthCallback)
+	auth.Post("/logout", s.Logout)
+
 	api := app.Group("/api/v1")
+
+	// Webhooks authenticate with their own token, so they are registered before the session check
+	if s.Cfg.GitLab.WebhookSecret != "" {
+		api.Post("/webhooks/gitlab", s.GitLabWebhook)
+	}
+
+	api.Use(s.RequireSession)
+
+	api.Get("/me", s.CurrentUser)
 	api.Get("/users/search", s.SearchUsers)
 	api.Get("/items/search", s.SearchItems)
+	api.Get("/items/resolve", s.ResolveItem)
+	api.Post("/items/batch", s.GetItemsBatch)
+	api.Get("/items/:id/relations", s.GetItemRelations)
+	api.Get("/items/:id", s.GetItem)
+	api.Get("/users/:id", s.GetUser)
+	api.Get("/events", s.IndexEvents)
+
+	api.Get("/graphs", s.ListGraphs)
+	api.Post("/graphs", s.CreateGraph)
+	api.Post("/graphs/generate", s.GenerateGraph)
+	api.Post("/graphs/import", s.ImportGraph)
+	api.Get("/graphs/:id", s.GetGraph)
+	api.Patch("/graphs/:id", s.RenameGraph)
+	api.Delete("/graphs/:id", s.DeleteGraph)
+
+	api.Get("/graphs/:id/events", s.GraphEvents)
+	api.Post("/graphs/:id/operations", s.ApplyOperations)
+	api.Post("/graphs/:id/layout", s.LayoutGraph)
+	api.Get("/graphs/:id/analysis", s.AnalyzeGraph)
+	api.Get("/graphs/:id/export", s.ExportGraph)
+	api.Get("/graphs/:id/history", s.GraphHistory)
+	api.Get("/graphs/:id/versions/:version", s.GetGraphVersion)
+	api.Get("/graphs/:id/diff", s.DiffGraph)
+	api.Post("/graphs/:id/restore", s.RestoreGraph)
+	api.Get("/graphs/:id/snapshots", s.ListSnapshots)
+	api.Post("/graphs/:id/snapshots", s.CreateSnapshot)
+	api.Delete("/graphs/:id/snapshots/:snapshotId", s.DeleteSnapshot)
+	api.Get("/graphs/:id/undo", s.UndoDepth)
+	api.Post("/graphs/:id/undo", s.Undo)
+	api.Post("/graphs/:id/redo", s.Redo)
+
+	api.Post("/graphs/:id/nodes", s.AddNode)
+	api.Patch("/graphs/:id/nodes/:nodeId", s.UpdateNode)
+	api.Delete("/graphs/:id/nodes/:nodeId", s.DeleteNode)
+
+	api.Post("/graphs/:id/edges", s.AddEdge)
+	api.Patch("/graphs/:id/edges/:edgeId", s.UpdateEdge)
+	api.Delete("/graphs/:id/edges/:edgeId", s.DeleteEdge)
 
 	frontend := os.DirFS("../frontend/dist")
 	app.Use("/", filesystem.New(filesystem.Config{
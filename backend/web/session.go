@@ -0,0 +1,76 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	sessionCookieName = "pathflux_session"
+	sessionDuration   = 7 * 24 * time.Hour
+	// sessionCheckInterval is how often RequireSession checks with GitLab that the user is still active
+	sessionCheckInterval = 15 * time.Minute
+)
+
+var errInvalidSession = errors.New("invalid session")
+
+// Session identifies the logged in GitLab user. It is stored in a signed cookie
+type Session struct {
+	UserID    int    `json:"id"`
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+
+	ExpiresAt int64 `json:"exp"`
+	CheckedAt int64 `json:"checked_at"`
+}
+
+func sign(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// encodeSession returns the cookie value for the session
+func encodeSession(secret []byte, session Session) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + sign(secret, payload), nil
+}
+
+// decodeSession verifies the cookie value and returns the session if it is still valid
+func decodeSession(secret []byte, value string) (Session, error) {
+	payload, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return Session{}, errInvalidSession
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(sign(secret, payload))) {
+		return Session{}, errInvalidSession
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Session{}, errInvalidSession
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return Session{}, errInvalidSession
+	}
+
+	if time.Now().Unix() > session.ExpiresAt {
+		return Session{}, errInvalidSession
+	}
+
+	return session, nil
+}
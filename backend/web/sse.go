@@ -0,0 +1,61 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// sseKeepAlive is how often a comment is sent on idle event streams, so proxies don't close them
+const sseKeepAlive = 30 * time.Second
+
+// streamEvents sets up a Server-Sent Events response and runs stream with a writer for it.
+// stream should return once writing fails, which means the client went away
+func streamEvents(c *fiber.Ctx, stream func(w *bufio.Writer)) error {
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(stream))
+
+	return nil
+}
+
+// writeEvent writes a single event and flushes it. id may be empty
+func writeEvent(w *bufio.Writer, event string, id string, data any) error {
+	var payload []byte
+	switch d := data.(type) {
+	case json.RawMessage:
+		payload = d
+	default:
+		var err error
+		payload, err = json.Marshal(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// writeKeepAlive writes an SSE comment line
+func writeKeepAlive(w *bufio.Writer) error {
+	if _, err := w.WriteString(": keep-alive\n\n"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+type undoDepthResponse struct {
+	Undo int `json:"undo"`
+	Redo int `json:"redo"`
+}
+
+// UndoDepth returns how many of their changes the current user can undo and redo
+func (s *Server) UndoDepth(c *fiber.Ctx) error {
+	undo, redo, err := s.Graphs.UndoDepth(c.Params("id"), currentSession(c).UserID)
+	if err != nil {
+		return graphError(err)
+	}
+	return c.JSON(undoDepthResponse{Undo: undo, Redo: redo})
+}
+
+// Undo reverts the current user's last change. The reverting operations are broadcast to all viewers and returned
+func (s *Server) Undo(c *fiber.Ctx) error {
+	applied, err := s.Graphs.Undo(c.Params("id"), currentSession(c).UserID, c.Get(clientIDHeader))
+	if err != nil {
+		return graphError(err)
+	}
+	return c.JSON(applied)
+}
+
+// Redo applies the current user's last undone change again
+func (s *Server) Redo(c *fiber.Ctx) error {
+	applied, err := s.Graphs.Redo(c.Params("id"), currentSession(c).UserID, c.Get(clientIDHeader))
+	if err != nil {
+		return graphError(err)
+	}
+	return c.JSON(applied)
+}
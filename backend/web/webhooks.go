@@ -0,0 +1,31 @@
+package web
+
+import (
+	"crypto/subtle"
+	"errors"
+	"pathflux/meili"
+
+	"github.com/gofiber/fiber/v2"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// GitLabWebhook receives issue, merge request, epic and member events, so changes show up without waiting for the next sync
+func (s *Server) GitLabWebhook(c *fiber.Ctx) error {
+	token := c.Get("X-Gitlab-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.Cfg.GitLab.WebhookSecret)) != 1 {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid webhook token")
+	}
+
+	eventType := gitlab.EventType(c.Get("X-Gitlab-Event"))
+
+	err := s.DB.HandleWebhook(c.Context(), eventType, c.Body())
+	if errors.Is(err, meili.ErrUnsupportedEvent) {
+		// Acknowledge anyway, otherwise GitLab disables the webhook after repeated failures
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
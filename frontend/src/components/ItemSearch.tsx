@@ -1,5 +1,5 @@
 import { useState, useEffect, useRef } from 'react';
-import { GitLabItem } from '@/lib/types';
+import { GitLabItem, SearchResult } from '@/lib/types';
 import GitLabItemCard from '@/components/GitLabItemCard';
 import { Select, SelectTrigger, SelectValue, SelectContent, SelectItem } from '@/components/ui/select';
 import { Separator } from '@/components/ui/separator';
@@ -61,12 +61,18 @@ export default function ItemSearch({
 				{ signal: abortController.signal }
 			);
 
+			if (response.status === 401) {
+				// Session missing or expired, log in with GitLab again
+				window.location.href = '/auth/login';
+				return;
+			}
+
 			if (!response.ok) {
 				throw new Error('Failed to fetch search results');
 			}
 
-			const data = await response.json();
-			setResults(data);
+			const data: SearchResult<GitLabItem> = await response.json();
+			setResults(data.hits);
 		} catch (err) {
 			// Don't set error if the request was aborted
 			if (!(err instanceof DOMException && err.name === 'AbortError')) {
@@ -1,6 +1,10 @@
-
+import { GitLabItem } from "./types";
 
 export interface Graph {
+	id: string;
+	name: string;
+	created_at: string;
+	updated_at: string;
 	nodes: Node[];
 	edges: Edge[];
 }
@@ -10,7 +14,7 @@ export interface Position {
 	y: number;
 }
 
-type NodeType = "text";
+type NodeType = "text" | "gitlab_item";
 
 export interface Node {
 	id: string;
@@ -30,9 +34,16 @@ export interface Edge {
 	type: string;
 	source: string;
 	target: string;
+	label?: string;
 	markerEnd: string;
 }
 
 export interface TextNode extends Node {
 	content: string;
 }
+
+export interface GitLabItemNode extends Node {
+	item_id: string;
+	// Filled in by the server with the current state of the item, missing if it is no longer indexed
+	item?: GitLabItem;
+}
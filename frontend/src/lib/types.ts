@@ -17,10 +17,25 @@ export interface User {
 	web_url: string;
 }
 
+export type RelationType =
+	| "relates_to"
+	| "blocks"
+	| "is_blocked_by"
+	| "parent"
+	| "child"
+	| "closes"
+	| "closed_by";
+
+export interface Relation {
+	type: RelationType;
+	item_id: string;
+}
+
 // Merges attributes from issues, epics and merge requests
 export interface GitLabItem {
 	id: string;
 	group_id: number;
+	project_id: number;
 	kind: string;
 	web_url: string;
 	slug: string;
@@ -33,4 +48,23 @@ export interface GitLabItem {
 	created_at: string;
 	updated_at: string;
 	closed_at: string | null;
+	created_at_ts: number;
+	updated_at_ts: number;
+	closed_at_ts?: number;
+	weight: number;
+	time_estimate: number;
+	relations: Relation[] | null;
+}
+
+export interface ItemRelation extends Relation {
+	item: GitLabItem;
+}
+
+export interface SearchResult<T> {
+	hits: T[];
+	estimatedTotalHits: number;
+	processingTimeMs: number;
+	limit: number;
+	offset: number;
+	facetDistribution?: Record<string, Record<string, number>>;
 }